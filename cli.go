@@ -2,14 +2,19 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/Workiva/go-datastructures/set"
 	"github.com/jhump/protoreflect/desc"
-	"github.com/jhump/protoreflect/desc/builder"
-	"github.com/jhump/protoreflect/desc/protoparse"
 	"github.com/jhump/protoreflect/desc/protoprint"
 	"github.com/urfave/cli/v2"
+	"github.com/wdullaer/proto-filter/grpcfilter"
+	"github.com/wdullaer/proto-filter/protofilter"
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
 )
 
 // RunCLI is the entrypoint for the cli app
@@ -19,12 +24,112 @@ func RunCLI() error {
 		Usage:     "Filter out objects in a proto file based on a filter option",
 		ArgsUsage: "[FILES]",
 		Action:    action,
+		Commands: []*cli.Command{
+			{
+				Name:      "lint",
+				Usage:     "Check the filter annotations in the proto files for mistakes",
+				ArgsUsage: "[FILES]",
+				Action:    lintAction,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "Read the inputs and include paths from `FILE` (default: proto-filter.yaml if it exists)",
+					},
+					&cli.StringSliceFlag{
+						Name:    "include",
+						Aliases: []string{"i"},
+						Usage:   "`PATH` to add to the lookup path for proto files",
+					},
+					&cli.StringSliceFlag{
+						Name:    "descriptor-set",
+						Aliases: []string{"d"},
+						Usage:   "Read the files from the binary or JSON FileDescriptorSet in `FILE` instead of parsing proto files. The arguments then select the files to filter",
+					},
+				},
+			},
+			{
+				Name:      "matrix",
+				Usage:     "Write a matrix of which term keeps which element, for every term used in the annotations",
+				ArgsUsage: "[FILES]",
+				Action:    matrixAction,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "Read the inputs, include paths and filter settings from `FILE` (default: proto-filter.yaml if it exists)",
+					},
+					&cli.StringSliceFlag{
+						Name:    "include",
+						Aliases: []string{"i"},
+						Usage:   "`PATH` to add to the lookup path for proto files",
+					},
+					&cli.StringSliceFlag{
+						Name:    "descriptor-set",
+						Aliases: []string{"d"},
+						Usage:   "Read the files from the binary or JSON FileDescriptorSet in `FILE` instead of parsing proto files. The arguments then select the files to filter",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "`FORMAT` of the matrix: csv, markdown or html",
+						Value: "csv",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Write the matrix to `FILE` instead of stdout",
+					},
+				},
+			},
+			{
+				Name:   "serve-reflection",
+				Usage:  "Serve a gRPC reflection service which filters the answers of an upstream reflection service for the terms of each client",
+				Action: serveReflectionAction,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "Read the filter settings from `FILE` (default: proto-filter.yaml if it exists)",
+					},
+					&cli.StringFlag{
+						Name:  "listen",
+						Usage: "`ADDRESS` to serve the filtered reflection service on",
+						Value: "localhost:50051",
+					},
+					&cli.StringFlag{
+						Name:     "upstream",
+						Usage:    "`ADDRESS` of the gRPC server with the reflection service to filter, connected to without TLS",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "terms-metadata",
+						Usage: "Metadata `KEY` the clients send their comma separated terms in",
+						Value: "x-proto-filter-terms",
+					},
+					&cli.StringSliceFlag{
+						Name:    "term",
+						Aliases: []string{"t"},
+						Usage:   "A `TERM` to filter for if a client sends no terms. Clients without terms are rejected if none are given",
+					},
+				},
+			},
+		},
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Aliases: []string{"c"},
+				Usage:   "Read the configuration from `FILE`, flags override its values (default: proto-filter.yaml if it exists)",
+			},
 			&cli.StringSliceFlag{
 				Name:    "include",
 				Aliases: []string{"i"},
 				Usage:   "`PATH` to add to the lookup path for proto files",
 			},
+			&cli.StringSliceFlag{
+				Name:    "descriptor-set",
+				Aliases: []string{"d"},
+				Usage:   "Read the files from the binary or JSON FileDescriptorSet in `FILE` instead of parsing proto files. The arguments then select the files to filter",
+			},
 			&cli.StringFlag{
 				Name:    "output",
 				Aliases: []string{"o"},
@@ -32,10 +137,72 @@ func RunCLI() error {
 				Value:   "./output/",
 			},
 			&cli.StringSliceFlag{
-				Name:     "term",
-				Aliases:  []string{"t"},
-				Usage:    "A `TERM` to filter for",
-				Required: true,
+				Name:    "term",
+				Aliases: []string{"t"},
+				Usage:   "A `TERM` to filter for, which can be a glob (partner.*) or a /regular expression/",
+			},
+			&cli.StringFlag{
+				Name:  "policy",
+				Usage: "`POLICY` for elements without a matching annotation: allow keeps them, deny removes them",
+				Value: protofilter.PolicyAllow.String(),
+			},
+			&cli.StringFlag{
+				Name:  "dangling",
+				Usage: "`MODE` for kept elements that refer to a removed type: fail reports them, cascade removes them",
+				Value: protofilter.DanglingFail.String(),
+			},
+			&cli.BoolFlag{
+				Name:  "shake",
+				Usage: "Remove all messages and enums that are not reachable from a kept service, extension or root type",
+			},
+			&cli.StringSliceFlag{
+				Name:  "root",
+				Usage: "Fully qualified name of a `TYPE` that --shake should always keep, which can be a glob or a /regular expression/",
+			},
+			&cli.BoolFlag{
+				Name:  "reserve",
+				Usage: "Add reserved numbers for all removed fields and enum values",
+			},
+			&cli.BoolFlag{
+				Name:  "reserve-names",
+				Usage: "Also add reserved names for all removed fields and enum values (implies --reserve)",
+			},
+			&cli.BoolFlag{
+				Name:  "clean",
+				Usage: "Remove the filter annotations and the import of filter.proto from the output",
+			},
+			&cli.BoolFlag{
+				Name:  "explain",
+				Usage: "Print why every element was kept or removed: its annotation, the matching terms and the rule that decided",
+			},
+			&cli.StringFlag{
+				Name:  "report",
+				Usage: "Write a JSON report of every removed element, with the reason it was removed, to `FILE`",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print a unified diff between the original and the filtered files instead of writing them",
+			},
+			&cli.BoolFlag{
+				Name:  "exit-code",
+				Usage: "Exit with status 1 if --dry-run finds differences",
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "`FORMAT` of the output: proto writes proto files, descriptor-set a binary FileDescriptorSet (filtered.pb) and json the same set as JSON (filtered.json)",
+				Value: formatProto,
+			},
+			&cli.BoolFlag{
+				Name:  "include-imports",
+				Usage: "Add all imported files to the FileDescriptorSet written by --format descriptor-set or json",
+			},
+			&cli.BoolFlag{
+				Name:  "include-source-info",
+				Usage: "Keep the source info, and with it the comments, in the FileDescriptorSet written by --format descriptor-set or json",
+			},
+			&cli.StringSliceFlag{
+				Name:  "variant",
+				Usage: "Filter a named `VARIANT` in the form name=term1,term2 into a subdirectory of the output. Can be repeated instead of --term",
 			},
 		},
 	}
@@ -44,47 +211,368 @@ func RunCLI() error {
 }
 
 func action(c *cli.Context) error {
-	config := Config{
-		Inputs:   c.Args().Slice(),
-		Output:   c.String("output"),
-		Includes: c.StringSlice("include"),
-		Terms:    makeStringSet(c.StringSlice("term")),
+	config, err := loadConfig(c)
+	if err != nil {
+		return fmt.Errorf("Invalid input: %s", err)
 	}
 
 	if errs := config.Validate(); len(errs) != 0 {
 		return fmt.Errorf("Invalid input: %s", errs)
 	}
 
-	parser := protoparse.Parser{
-		ImportPaths:           config.Includes,
-		InferImportPaths:      true,
-		IncludeSourceCodeInfo: true,
-	}
-	descs, err := parser.ParseFiles(config.Inputs...)
+	descs, err := config.Load()
 	if err != nil {
 		return err
 	}
+	if err := checkDeclaredTerms(&config, descs); err != nil {
+		return err
+	}
 
-	output := make([]*desc.FileDescriptor, 0, len(descs))
-	for _, fdesc := range descs {
-		fileBuilder, err := builder.FromFile(fdesc)
+	printer := protoprint.Printer{}
+	var report Report
+	changed := false
+	for _, variant := range config.Variants {
+		result, differs, err := filterVariant(c, &config, &printer, descs, variant)
 		if err != nil {
 			return err
 		}
-		if result, err := filterFile(fileBuilder, config.Terms); err != nil {
+		changed = changed || differs
+		report.Variants = append(report.Variants, newVariantReport(variant.Name, result.Removed))
+	}
+	if len(config.Report) != 0 {
+		if err := writeReport(report, config.Report); err != nil {
 			return err
-		} else if result {
-			fDesc, err := fileBuilder.Build()
+		}
+	}
+	if config.ExitCode && changed {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+// filterVariant filters the files for the variant and writes them to its
+// output, or prints their diff on a dry run. It returns whether the dry run
+// found any differences.
+func filterVariant(c *cli.Context, config *Config, printer *protoprint.Printer, descs []*desc.FileDescriptor, variant Variant) (*protofilter.Result, bool, error) {
+	prefix := ""
+	if len(variant.Name) != 0 {
+		prefix = variant.Name + ": "
+	}
+	options := config.Options(variant)
+	if config.Explain {
+		options.Explain = func(explanation protofilter.Explanation) {
+			fmt.Fprintf(c.App.Writer, "%s%s\n", prefix, explanation)
+		}
+	}
+	result, err := protofilter.Filter(descs, options)
+	if err != nil {
+		if len(variant.Name) != 0 {
+			return nil, false, fmt.Errorf("Variant %s: %s", variant.Name, err)
+		}
+		return nil, false, err
+	}
+	if config.Shake {
+		fmt.Fprint(c.App.Writer, prefix+formatRemovedTypes(result.RemovedTypes))
+	}
+	if !config.DryRun {
+		return result, false, writeOutput(config, printer, result.Files, variant.Output)
+	}
+	diff, err := diffFiles(printer, descs, result.Files, variant.Output)
+	if err != nil {
+		return nil, false, err
+	}
+	fmt.Fprint(c.App.Writer, diff)
+	return result, len(diff) != 0, nil
+}
+
+// lintAction reports the problems with the annotations in the inputs. It
+// fails if any of them is an error.
+func lintAction(c *cli.Context) error {
+	config, err := loadConfig(c)
+	if err != nil {
+		return fmt.Errorf("Invalid input: %s", err)
+	}
+	if len(config.Inputs) == 0 && len(config.DescriptorSets) == 0 {
+		return fmt.Errorf("Invalid input: %s", errNoInputs)
+	}
+
+	descs, err := config.Load()
+	if err != nil {
+		return err
+	}
+	problems, err := protofilter.Lint(descs)
+	if err != nil {
+		return err
+	}
+	registry, err := config.Registry(descs)
+	if err != nil {
+		return err
+	}
+	if registry.Len() != 0 {
+		undeclared, err := registry.Check(descs)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, undeclared...)
+	}
+	errs := 0
+	for _, problem := range problems {
+		fmt.Fprintln(c.App.Writer, problem)
+		if problem.Severity == protofilter.SeverityError {
+			errs++
+		}
+	}
+	if errs != 0 {
+		return cli.Exit(fmt.Sprintf("Found %d problems, %d of them errors", len(problems), errs), 1)
+	}
+	return nil
+}
+
+// matrixAction writes the visibility matrix of the inputs for every term that
+// is used in their annotations or declared
+func matrixAction(c *cli.Context) error {
+	write, ok := matrixWriter(c.String("format"))
+	if !ok {
+		return fmt.Errorf("Invalid input: Unknown format %q, expected csv, markdown or html", c.String("format"))
+	}
+	config, err := loadConfig(c)
+	if err != nil {
+		return fmt.Errorf("Invalid input: %s", err)
+	}
+	if len(config.Inputs) == 0 && len(config.DescriptorSets) == 0 {
+		return fmt.Errorf("Invalid input: %s", errNoInputs)
+	}
+
+	descs, err := config.Load()
+	if err != nil {
+		return err
+	}
+	terms, err := protofilter.UsedTerms(descs)
+	if err != nil {
+		return err
+	}
+	registry, err := config.Registry(descs)
+	if err != nil {
+		return err
+	}
+	if registry.Len() != 0 {
+		terms = mergeTerms(terms, registry.Definitions())
+	}
+	matrix, err := protofilter.NewVisibilityMatrix(descs, terms, config.Options(Variant{}))
+	if err != nil {
+		return err
+	}
+
+	if len(c.String("output")) == 0 {
+		return write(c.App.Writer, matrix)
+	}
+	f, err := os.Create(c.String("output"))
+	if err != nil {
+		return err
+	}
+	if err := write(f, matrix); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// serveReflectionAction serves the filtering reflection proxy until the
+// program is stopped
+func serveReflectionAction(c *cli.Context) error {
+	config, err := loadConfig(c)
+	if err != nil {
+		return fmt.Errorf("Invalid input: %s", err)
+	}
+	var defaults []string
+	if config.Terms != nil {
+		for _, term := range config.Terms.Flatten() {
+			defaults = append(defaults, term.(string))
+		}
+	}
+
+	upstream, err := grpc.Dial(c.String("upstream"), grpc.WithInsecure())
+	if err != nil {
+		return err
+	}
+	defer upstream.Close()
+	listener, err := net.Listen("tcp", c.String("listen"))
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer()
+	proxy := grpcfilter.NewReflectionProxy(
+		rpb.NewServerReflectionClient(upstream),
+		grpcfilter.TermsFromMetadata(c.String("terms-metadata"), defaults),
+		config.Options(Variant{}),
+	)
+	proxy.Register(server)
+	fmt.Fprintf(c.App.Writer, "Serving the reflection service of %s on %s\n", c.String("upstream"), listener.Addr())
+	return server.Serve(listener)
+}
+
+// mergeTerms adds the declared terms to the sorted terms
+func mergeTerms(terms []string, definitions []protofilter.TermDefinition) []string {
+	merged := makeStringSet(terms)
+	for _, definition := range definitions {
+		merged.Add(definition.Name)
+	}
+	result := make([]string, 0, merged.Len())
+	for _, term := range merged.Flatten() {
+		result = append(result, term.(string))
+	}
+	sort.Strings(result)
+	return result
+}
+
+// checkDeclaredTerms rejects the terms given to the program and the terms used
+// in annotations if they are not declared. Projects that do not declare their
+// terms can use any term.
+func checkDeclaredTerms(config *Config, descs []*desc.FileDescriptor) error {
+	registry, err := config.Registry(descs)
+	if err != nil || registry.Len() == 0 {
+		return err
+	}
+	if errs := config.ValidateDeclaredTerms(registry); len(errs) != 0 {
+		names := make([]string, 0, registry.Len())
+		for _, definition := range registry.Definitions() {
+			names = append(names, definition.Name)
+		}
+		return fmt.Errorf("Invalid input: %s, the declared terms are %s", errs, strings.Join(names, ", "))
+	}
+	problems, err := registry.Check(descs)
+	if err != nil || len(problems) == 0 {
+		return err
+	}
+	lines := make([]string, len(problems))
+	for i, problem := range problems {
+		lines[i] = problem.String()
+	}
+	return fmt.Errorf("Annotations use undeclared terms:\n%s", strings.Join(lines, "\n"))
+}
+
+// loadConfig creates the Config from the config file, if there is one, and
+// the command line. Flags that are set override the values in the file.
+func loadConfig(c *cli.Context) (Config, error) {
+	config, err := readConfigFile(c.String("config"))
+	if err != nil {
+		return config, err
+	}
+
+	if c.Args().Present() {
+		config.Inputs = c.Args().Slice()
+		config.source.forget("inputs")
+	}
+	if c.IsSet("descriptor-set") {
+		config.DescriptorSets = c.StringSlice("descriptor-set")
+		config.source.forget("descriptor_sets")
+	}
+	if c.IsSet("include") {
+		config.Includes = c.StringSlice("include")
+		config.source.forget("includes")
+	}
+	if err := applyFilterFlags(c, &config); err != nil {
+		return config, err
+	}
+	applyOutputFlags(c, &config)
+	return config, nil
+}
+
+// readConfigFile loads the config file at path, or the default config file if
+// path is empty and it exists. Without a config file the Config is empty.
+func readConfigFile(path string) (Config, error) {
+	if len(path) == 0 {
+		if _, err := os.Stat(defaultConfigFile); err != nil {
+			return Config{}, nil
+		}
+		path = defaultConfigFile
+	}
+	return LoadConfigFile(path)
+}
+
+// applyFilterFlags overrides the settings that determine what is filtered
+// with the flags that are set
+func applyFilterFlags(c *cli.Context, config *Config) error {
+	// Terms and variants are mutually exclusive, so either one replaces both
+	if c.IsSet("term") || c.IsSet("variant") {
+		config.Terms = makeStringSet(c.StringSlice("term"))
+		config.Variants = nil
+		config.source.forget("terms")
+		config.source.forget("variants")
+		for _, value := range c.StringSlice("variant") {
+			variant, err := ParseVariant(value)
 			if err != nil {
 				return err
 			}
-			output = append(output, fDesc)
+			config.Variants = append(config.Variants, variant)
 		}
 	}
+	if c.IsSet("policy") {
+		policy, err := protofilter.ParsePolicy(c.String("policy"))
+		if err != nil {
+			return err
+		}
+		config.Policy = policy
+	}
+	if c.IsSet("dangling") {
+		dangling, err := protofilter.ParseDanglingMode(c.String("dangling"))
+		if err != nil {
+			return err
+		}
+		config.Dangling = dangling
+	}
+	if c.IsSet("root") {
+		config.Roots = c.StringSlice("root")
+		config.source.forget("roots")
+	}
+	applyBoolFlags(c, map[string]*bool{
+		"shake":         &config.Shake,
+		"reserve":       &config.Reserve,
+		"reserve-names": &config.ReserveNames,
+		"clean":         &config.Clean,
+	})
+	return nil
+}
 
-	printer := protoprint.Printer{}
+// applyOutputFlags overrides the settings that determine how the result is
+// written with the flags that are set
+func applyOutputFlags(c *cli.Context, config *Config) {
+	if c.IsSet("output") || len(config.Output) == 0 {
+		config.Output = c.String("output")
+		config.source.forget("output")
+	}
+	if c.IsSet("format") {
+		config.Format = c.String("format")
+		config.source.forget("format")
+	}
+	if c.IsSet("report") {
+		config.Report = c.String("report")
+	}
+	applyBoolFlags(c, map[string]*bool{
+		"explain":             &config.Explain,
+		"dry-run":             &config.DryRun,
+		"exit-code":           &config.ExitCode,
+		"include-imports":     &config.IncludeImports,
+		"include-source-info": &config.IncludeSourceInfo,
+	})
+}
 
-	return printer.PrintProtosToFileSystem(output, config.Output)
+// applyBoolFlags sets every value to its flag, if the flag is set
+func applyBoolFlags(c *cli.Context, flags map[string]*bool) {
+	for name, value := range flags {
+		if c.IsSet(name) {
+			*value = c.Bool(name)
+		}
+	}
+}
+
+// formatRemovedTypes renders the result of shakeTypes for the user
+func formatRemovedTypes(removed []string) string {
+	if len(removed) == 0 {
+		return "No unreferenced types were removed\n"
+	}
+	return "Removed unreferenced types:\n  " + strings.Join(removed, "\n  ") + "\n"
 }
 
 // makeStringSet is a convenience wrapper which produces a new Set from a slice of strings
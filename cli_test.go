@@ -37,3 +37,8 @@ func TestMakeStringSet(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatRemovedTypes(t *testing.T) {
+	assert.Equal(t, "No unreferenced types were removed\n", formatRemovedTypes(nil))
+	assert.Equal(t, "Removed unreferenced types:\n  a.B\n  a.C\n", formatRemovedTypes([]string{"a.B", "a.C"}))
+}
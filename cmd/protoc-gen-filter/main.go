@@ -0,0 +1,169 @@
+// Command protoc-gen-filter runs proto-filter as a protoc plugin, so it can use
+// the files protoc (or buf) already parsed:
+//
+//	protoc --filter_out=term=NA,clean:./output test.proto
+//
+// The plugin parameter is a comma separated list of options:
+//
+//	term=TERM         a term to filter for, can be repeated
+//	policy=POLICY     allow (default) or deny
+//	dangling=MODE     fail (default) or cascade
+//	shake             remove unreferenced types
+//	root=TYPE         a type shake should always keep, can be repeated
+//	reserve           reserve the numbers of removed fields and enum values
+//	reserve_names     also reserve their names
+//	clean             remove the filter annotations
+//	format=FORMAT     proto (default) writes the filtered .proto files,
+//	                  descriptor_set writes a FileDescriptorSet
+//	descriptor_set=NAME  the name of the FileDescriptorSet (default: filtered.pb)
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/jhump/protoreflect/desc/protoprint"
+	"github.com/wdullaer/proto-filter/protofilter"
+)
+
+func main() {
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var request plugin.CodeGeneratorRequest
+	if err := proto.Unmarshal(data, &request); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid CodeGeneratorRequest: %s\n", err)
+		os.Exit(1)
+	}
+
+	output, err := proto.Marshal(generate(&request))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if _, err := os.Stdout.Write(output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// pluginConfig holds the options from the plugin parameter
+type pluginConfig struct {
+	options       protofilter.Options
+	format        string
+	descriptorSet string
+}
+
+// parseParameter parses the plugin parameter into the filter options
+func parseParameter(parameter string) (pluginConfig, error) {
+	config := pluginConfig{format: "proto", descriptorSet: "filtered.pb"}
+	for _, item := range strings.Split(parameter, ",") {
+		if len(item) == 0 {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		key, value := parts[0], ""
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+		if err := config.set(key, value); err != nil {
+			return config, err
+		}
+	}
+	if len(config.options.Terms) == 0 {
+		return config, fmt.Errorf("No terms given to filter for, add term=TERM to the parameter")
+	}
+	return config, nil
+}
+
+// set applies a single key=value item of the plugin parameter
+func (c *pluginConfig) set(key string, value string) error {
+	var err error
+	switch key {
+	case "term":
+		c.options.Terms = append(c.options.Terms, value)
+		err = protofilter.ValidateTerm(value)
+	case "policy":
+		c.options.Policy, err = protofilter.ParsePolicy(value)
+	case "dangling":
+		c.options.Dangling, err = protofilter.ParseDanglingMode(value)
+	case "shake":
+		c.options.Shake = true
+	case "root":
+		c.options.Roots = append(c.options.Roots, value)
+		err = protofilter.ValidateTerm(value)
+	case "reserve":
+		c.options.Reserve = true
+	case "reserve_names":
+		c.options.ReserveNames = true
+	case "clean":
+		c.options.Clean = true
+	case "format":
+		if value != "proto" && value != "descriptor_set" {
+			err = fmt.Errorf("Unknown format %q, expected proto or descriptor_set", value)
+		}
+		c.format = value
+	case "descriptor_set":
+		c.descriptorSet = value
+	default:
+		err = fmt.Errorf("Unknown parameter %q", key)
+	}
+	return err
+}
+
+// generate filters the files in the request. Errors are reported in the
+// response, as the plugin protocol expects.
+func generate(request *plugin.CodeGeneratorRequest) *plugin.CodeGeneratorResponse {
+	files, err := filterRequest(request)
+	if err != nil {
+		return &plugin.CodeGeneratorResponse{Error: proto.String(err.Error())}
+	}
+	return &plugin.CodeGeneratorResponse{File: files}
+}
+
+func filterRequest(request *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	config, err := parseParameter(request.GetParameter())
+	if err != nil {
+		return nil, err
+	}
+	descs, err := protofilter.FromFileDescriptorProtos(request.GetProtoFile(), request.GetFileToGenerate())
+	if err != nil {
+		return nil, err
+	}
+	result, err := protofilter.Filter(descs, config.options)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.format == "descriptor_set" {
+		data, err := proto.Marshal(protofilter.DescriptorSet(result.Files, protofilter.DescriptorSetOptions{IncludeImports: true}))
+		if err != nil {
+			return nil, err
+		}
+		return []*plugin.CodeGeneratorResponse_File{{
+			Name:    proto.String(config.descriptorSet),
+			Content: proto.String(string(data)),
+		}}, nil
+	}
+
+	printer := protoprint.Printer{}
+	files := make([]*plugin.CodeGeneratorResponse_File, 0, len(result.Files))
+	for _, fd := range result.Files {
+		var buf bytes.Buffer
+		if err := printer.PrintProtoFile(fd, &buf); err != nil {
+			return nil, err
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(fd.GetName()),
+			Content: proto.String(buf.String()),
+		})
+	}
+	return files, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wdullaer/proto-filter/protofilter"
+)
+
+// newTestRequest is a test helper which creates the request protoc would send
+// for the example file
+func newTestRequest(t *testing.T, parameter string) *plugin.CodeGeneratorRequest {
+	descs, err := protofilter.Parse([]string{"example/test.proto"}, []string{"../.."})
+	require.NoError(t, err)
+	return &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{"example/test.proto"},
+		Parameter:      proto.String(parameter),
+		ProtoFile:      protofilter.DescriptorSet(descs, protofilter.DescriptorSetOptions{IncludeImports: true}).GetFile(),
+	}
+}
+
+func TestParseParameter(t *testing.T) {
+	t.Run("Should parse all options", func(t *testing.T) {
+		config, err := parseParameter("term=NA,term=partner.*,policy=deny,dangling=cascade,shake,root=com.*,reserve,reserve_names,clean,format=descriptor_set,descriptor_set=out.pb")
+		if assert.NoError(t, err) {
+			assert.Equal(t, protofilter.Options{
+				Terms:        []string{"NA", "partner.*"},
+				Policy:       protofilter.PolicyDeny,
+				Dangling:     protofilter.DanglingCascade,
+				Shake:        true,
+				Roots:        []string{"com.*"},
+				Reserve:      true,
+				ReserveNames: true,
+				Clean:        true,
+			}, config.options)
+			assert.Equal(t, "descriptor_set", config.format)
+			assert.Equal(t, "out.pb", config.descriptorSet)
+		}
+	})
+
+	cases := []struct {
+		name      string
+		parameter string
+		output    string
+	}{
+		{name: "Should require a term", parameter: "", output: "No terms given to filter for, add term=TERM to the parameter"},
+		{name: "Should reject unknown parameters", parameter: "term=NA,foo", output: `Unknown parameter "foo"`},
+		{name: "Should reject unknown formats", parameter: "term=NA,format=json", output: `Unknown format "json", expected proto or descriptor_set`},
+		{name: "Should reject invalid terms", parameter: "term=/(/", output: "Invalid regular expression in term \"/(/\": error parsing regexp: missing closing ): `(`"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseParameter(tc.parameter)
+			assert.EqualError(t, err, tc.output)
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("Should return the filtered proto files", func(t *testing.T) {
+		response := generate(newTestRequest(t, "term=NA,clean"))
+
+		require.Empty(t, response.GetError())
+		if assert.Len(t, response.GetFile(), 1) {
+			file := response.GetFile()[0]
+			assert.Equal(t, "example/test.proto", file.GetName())
+			assert.Contains(t, file.GetContent(), "na_string")
+			assert.NotContains(t, file.GetContent(), "jp_string")
+			assert.NotContains(t, file.GetContent(), "filter.proto")
+		}
+	})
+
+	t.Run("Should return the filtered files as a FileDescriptorSet", func(t *testing.T) {
+		response := generate(newTestRequest(t, "term=NA,format=descriptor_set"))
+
+		require.Empty(t, response.GetError())
+		if assert.Len(t, response.GetFile(), 1) {
+			assert.Equal(t, "filtered.pb", response.GetFile()[0].GetName())
+			var set dpb.FileDescriptorSet
+			require.NoError(t, proto.Unmarshal([]byte(response.GetFile()[0].GetContent()), &set))
+			names := make([]string, len(set.GetFile()))
+			for i, file := range set.GetFile() {
+				names[i] = file.GetName()
+			}
+			assert.Equal(t, []string{"google/protobuf/descriptor.proto", "filter/filter.proto", "example/test.proto"}, names)
+			descs, err := protofilter.FromFileDescriptorProtos(set.GetFile(), []string{"example/test.proto"})
+			if assert.NoError(t, err) {
+				message := descs[0].FindMessage("com.test.Test")
+				assert.NotNil(t, message.FindFieldByName("na_string"))
+				assert.Nil(t, message.FindFieldByName("jp_string"))
+			}
+		}
+	})
+
+	t.Run("Should report errors in the response", func(t *testing.T) {
+		response := generate(newTestRequest(t, "policy=deny"))
+
+		assert.Equal(t, "No terms given to filter for, add term=TERM to the parameter", response.GetError())
+		assert.Empty(t, response.GetFile())
+	})
+}
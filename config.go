@@ -2,21 +2,90 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
 
 	"github.com/Workiva/go-datastructures/set"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/wdullaer/proto-filter/protofilter"
 )
 
 // Config encapsulates all configuration for the program
 type Config struct {
-	Inputs   []string
-	Output   string
-	Includes []string
-	Terms    *set.Set
+	// Inputs are the proto files to filter, or the names of the files to
+	// filter in DescriptorSets if those are given
+	Inputs []string
+	// DescriptorSets are compiled FileDescriptorSets to read instead of
+	// parsing proto files
+	DescriptorSets []string
+	Output         string
+	Includes       []string
+	Terms          *set.Set
+	Policy         protofilter.Policy
+	Dangling       protofilter.DanglingMode
+	Shake          bool
+	Roots          []string
+	// Reserve adds reserved numbers for removed fields and enum values
+	Reserve bool
+	// ReserveNames also reserves their names, which implies Reserve
+	ReserveNames bool
+	// Clean removes the filter annotations from the output
+	Clean bool
+	// Explain prints why every element was kept or removed
+	Explain bool
+	// DeclaredTerms are the terms the project allows, in addition to the
+	// terms declared in the proto files
+	DeclaredTerms []protofilter.TermDefinition
+	// Report is the path of the JSON report of the removed elements, if any
+	Report string
+	// DryRun prints a diff of the changes instead of writing the output
+	DryRun bool
+	// ExitCode makes a dry run fail if there are differences
+	ExitCode bool
+	// Format is the format of the output: proto files, or a binary or JSON
+	// FileDescriptorSet
+	Format string
+	// IncludeImports adds the imported files to a FileDescriptorSet
+	IncludeImports bool
+	// IncludeSourceInfo keeps the source info, and with it the comments, in
+	// a FileDescriptorSet
+	IncludeSourceInfo bool
+	// Variants are filtered from the same inputs in a single run. Validate
+	// turns Terms and Output into a single unnamed variant if none are given.
+	Variants []Variant
+
+	// source is set if the configuration was loaded from a file
+	source *configSource
+}
+
+// Variant is a named set of terms to filter for, with its own output directory
+type Variant struct {
+	Name   string
+	Terms  *set.Set
+	Output string
+}
+
+// ParseVariant parses a variant in the form `name=term1,term2`
+func ParseVariant(value string) (Variant, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return Variant{}, fmt.Errorf("Invalid variant %q, expected `name=term1,term2`", value)
+	}
+	terms := set.New()
+	for _, term := range strings.Split(parts[1], ",") {
+		if len(term) != 0 {
+			terms.Add(term)
+		}
+	}
+	return Variant{Name: parts[0], Terms: terms}, nil
 }
 
 var (
 	errNoInputs = errors.New("No files given to process")
 	errNoTerms  = errors.New("No terms given to filter for")
+
+	errTermsAndVariants = errors.New("Terms can not be combined with variants, add them to the variants instead")
 )
 
 // Validate performs a limited set of validations on the configuration to make
@@ -24,17 +93,160 @@ var (
 func (c *Config) Validate() []error {
 	var errs = make([]error, 0, 5)
 
-	if len(c.Inputs) == 0 {
+	if len(c.Inputs) == 0 && len(c.DescriptorSets) == 0 {
 		errs = append(errs, errNoInputs)
 	}
 
-	if c.Terms == nil || c.Terms.Len() == 0 {
-		errs = append(errs, errNoTerms)
+	if len(c.Variants) == 0 {
+		errs = append(errs, c.validateTerms(c.Terms, "terms", errNoTerms)...)
+	} else if c.Terms != nil && c.Terms.Len() != 0 {
+		errs = append(errs, c.source.wrap("terms", errTermsAndVariants))
+	}
+
+	errs = append(errs, c.validateVariants()...)
+
+	for i, root := range c.Roots {
+		if err := protofilter.ValidateTerm(root); err != nil {
+			errs = append(errs, c.source.wrap(fmt.Sprintf("roots[%d]", i), err))
+		}
+	}
+
+	for i, definition := range c.DeclaredTerms {
+		if _, err := protofilter.NewRegistry([]protofilter.TermDefinition{definition}); err != nil {
+			errs = append(errs, c.source.wrap(fmt.Sprintf("declared_terms[%d].name", i), err))
+		}
+	}
+
+	if len(c.Format) == 0 {
+		c.Format = formatProto
+	} else if !isOutputFormat(c.Format) {
+		errs = append(errs, c.source.wrap("format", fmt.Errorf("Invalid format %q, expected one of %s", c.Format, strings.Join(outputFormats(), ", "))))
+	}
+
+	c.applyDefaults()
+	return errs
+}
+
+// validateVariants checks that every variant has a unique name and valid terms
+func (c *Config) validateVariants() []error {
+	var errs []error
+	names := make(map[string]struct{}, len(c.Variants))
+	for i, variant := range c.Variants {
+		key := fmt.Sprintf("variants[%d]", i)
+		if len(variant.Name) == 0 {
+			errs = append(errs, c.source.wrap(key, errors.New("Variants must have a name")))
+		} else if _, ok := names[variant.Name]; ok {
+			errs = append(errs, c.source.wrap(key+".name", fmt.Errorf("Variant %q is defined more than once", variant.Name)))
+		}
+		names[variant.Name] = struct{}{}
+		errs = append(errs, c.validateTerms(variant.Terms, key+".terms", fmt.Errorf("No terms given to filter for in variant %q", variant.Name))...)
+	}
+	return errs
+}
+
+// applyDefaults fills in the settings that were not configured
+func (c *Config) applyDefaults() {
+	if c.ReserveNames {
+		c.Reserve = true
 	}
 
 	if len(c.Output) == 0 {
 		c.Output = "./output"
 	}
 
+	if len(c.Variants) == 0 {
+		c.Variants = []Variant{{Terms: c.Terms, Output: c.Output}}
+	}
+	for i := range c.Variants {
+		if len(c.Variants[i].Output) == 0 {
+			c.Variants[i].Output = filepath.Join(c.Output, c.Variants[i].Name)
+		}
+	}
+}
+
+// isOutputFormat reports whether format is one of the outputFormats
+func isOutputFormat(format string) bool {
+	for _, f := range outputFormats() {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTerms checks that the set contains at least one term and that every
+// term compiles. It returns errEmpty if the set is empty. key is the location
+// of the terms in the config file.
+func (c *Config) validateTerms(terms *set.Set, key string, errEmpty error) []error {
+	if terms == nil || terms.Len() == 0 {
+		return []error{c.source.wrap(key, errEmpty)}
+	}
+	var errs []error
+	for _, term := range terms.Flatten() {
+		if err := protofilter.ValidateTerm(term.(string)); err != nil {
+			errs = append(errs, c.source.wrap(c.source.elementKey(key, term.(string)), err))
+		}
+	}
 	return errs
 }
+
+// Load returns the descriptors of the inputs, parsed from the proto files or
+// read from the descriptor sets
+func (c *Config) Load() ([]*desc.FileDescriptor, error) {
+	if len(c.DescriptorSets) != 0 {
+		return protofilter.LoadDescriptorSets(c.DescriptorSets, c.Inputs)
+	}
+	return protofilter.Parse(c.Inputs, c.Includes)
+}
+
+// Registry returns the registry of the terms declared in the config and in
+// the files
+func (c *Config) Registry(descs []*desc.FileDescriptor) (*protofilter.Registry, error) {
+	definitions, err := protofilter.DeclaredTerms(descs)
+	if err != nil {
+		return nil, err
+	}
+	return protofilter.NewRegistry(append(append([]protofilter.TermDefinition(nil), c.DeclaredTerms...), definitions...))
+}
+
+// ValidateDeclaredTerms checks that the terms of every variant are declared in
+// the registry. It has to be called after Validate.
+func (c *Config) ValidateDeclaredTerms(registry *protofilter.Registry) []error {
+	var errs []error
+	for i, variant := range c.Variants {
+		if variant.Terms == nil {
+			continue
+		}
+		// The unnamed variant is created from Terms by Validate
+		key := fmt.Sprintf("variants[%d].terms", i)
+		if len(variant.Name) == 0 {
+			key = "terms"
+		}
+		for _, term := range variant.Terms.Flatten() {
+			if err := registry.ValidateTerm(term.(string)); err != nil {
+				errs = append(errs, c.source.wrap(c.source.elementKey(key, term.(string)), err))
+			}
+		}
+	}
+	return errs
+}
+
+// Options returns the options to filter the variant with
+func (c *Config) Options(variant Variant) protofilter.Options {
+	var terms []string
+	if variant.Terms != nil {
+		for _, term := range variant.Terms.Flatten() {
+			terms = append(terms, term.(string))
+		}
+	}
+	return protofilter.Options{
+		Terms:        terms,
+		Policy:       c.Policy,
+		Dangling:     c.Dangling,
+		Shake:        c.Shake,
+		Roots:        c.Roots,
+		Reserve:      c.Reserve,
+		ReserveNames: c.ReserveNames,
+		Clean:        c.Clean,
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/Workiva/go-datastructures/set"
@@ -40,6 +41,14 @@ func TestConfigValidate(t *testing.T) {
 			},
 			errs: []error{},
 		},
+		{
+			name: "Should accept descriptor sets instead of inputs",
+			input: &Config{
+				DescriptorSets: []string{"api.pb"},
+				Terms:          set.New("foo"),
+			},
+			errs: []error{},
+		},
 		{
 			name: "Should not return errors if a full valid config is given",
 			input: &Config{
@@ -58,6 +67,41 @@ func TestConfigValidate(t *testing.T) {
 		})
 	}
 
+	t.Run("Should default Format to proto", func(t *testing.T) {
+		config := &Config{Inputs: []string{"./"}, Terms: set.New("foo")}
+		assert.Empty(t, config.Validate())
+		assert.Equal(t, "proto", config.Format)
+	})
+
+	t.Run("Should return an error for an unknown format", func(t *testing.T) {
+		config := &Config{Inputs: []string{"./"}, Terms: set.New("foo"), Format: "yaml"}
+		errs := config.Validate()
+		if assert.Len(t, errs, 1) {
+			assert.EqualError(t, errs[0], `Invalid format "yaml", expected one of proto, descriptor-set, json`)
+		}
+	})
+
+	t.Run("Should return an error if a term is an invalid regular expression", func(t *testing.T) {
+		input := &Config{
+			Inputs: []string{"./"},
+			Terms:  set.New("foo", "/(bar/"),
+		}
+
+		assert.Len(t, input.Validate(), 1)
+	})
+
+	t.Run("Should enable Reserve if ReserveNames is set", func(t *testing.T) {
+		input := &Config{
+			Inputs:       []string{"./"},
+			Terms:        set.New("foo"),
+			ReserveNames: true,
+		}
+
+		if assert.Empty(t, input.Validate()) {
+			assert.True(t, input.Reserve)
+		}
+	})
+
 	t.Run("Should set Output to `./output` if it is empty", func(t *testing.T) {
 		input := &Config{
 			Inputs: []string{"./"},
@@ -69,4 +113,73 @@ func TestConfigValidate(t *testing.T) {
 			assert.Equal(t, expected, input.Output, "Expected Config.Validate() to set Output to `%s`", expected)
 		}
 	})
+
+	t.Run("Should turn Terms and Output into a single variant", func(t *testing.T) {
+		input := &Config{
+			Inputs: []string{"./"},
+			Terms:  set.New("foo"),
+			Output: "./folder",
+		}
+
+		if assert.Empty(t, input.Validate()) {
+			assert.Equal(t, []Variant{{Terms: input.Terms, Output: "./folder"}}, input.Variants)
+		}
+	})
+
+	t.Run("Should write every variant to a subdirectory of Output by default", func(t *testing.T) {
+		input := &Config{
+			Inputs: []string{"./"},
+			Output: "./folder",
+			Variants: []Variant{
+				{Name: "foo", Terms: set.New("foo")},
+				{Name: "bar", Terms: set.New("bar"), Output: "./bar"},
+			},
+		}
+
+		if assert.Empty(t, input.Validate()) {
+			assert.Equal(t, "folder/foo", input.Variants[0].Output)
+			assert.Equal(t, "./bar", input.Variants[1].Output)
+		}
+	})
+
+	t.Run("Should return an error if Terms and Variants are both given", func(t *testing.T) {
+		input := &Config{
+			Inputs:   []string{"./"},
+			Terms:    set.New("foo"),
+			Variants: []Variant{{Name: "foo", Terms: set.New("foo")}},
+		}
+
+		assert.Equal(t, []error{errTermsAndVariants}, input.Validate())
+	})
+
+	t.Run("Should return an error for invalid variants", func(t *testing.T) {
+		input := &Config{
+			Inputs: []string{"./"},
+			Variants: []Variant{
+				{Name: "foo", Terms: set.New("foo")},
+				{Name: "foo", Terms: set.New("bar")},
+				{Name: "", Terms: set.New("bar")},
+				{Name: "empty", Terms: set.New()},
+				{Name: "invalid", Terms: set.New("/(bar/")},
+			},
+		}
+
+		assert.Len(t, input.Validate(), 4)
+	})
+}
+
+func TestParseVariant(t *testing.T) {
+	t.Run("Should parse the name and terms of the variant", func(t *testing.T) {
+		if result, err := ParseVariant("partners=partner.*,NA"); assert.NoError(t, err) {
+			assert.Equal(t, "partners", result.Name)
+			assert.ElementsMatch(t, []interface{}{"partner.*", "NA"}, result.Terms.Flatten())
+		}
+	})
+
+	for _, value := range []string{"partners", "=NA"} {
+		t.Run(fmt.Sprintf("Should return an error for `%s`", value), func(t *testing.T) {
+			_, err := ParseVariant(value)
+			assert.Error(t, err)
+		})
+	}
 }
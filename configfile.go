@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/wdullaer/proto-filter/protofilter"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is loaded from the working directory if no config file is
+// given explicitly
+const defaultConfigFile = "proto-filter.yaml"
+
+// fileConfig is the schema of the config file
+type fileConfig struct {
+	Inputs            []string             `yaml:"inputs"`
+	DescriptorSets    []string             `yaml:"descriptor_sets"`
+	Output            string               `yaml:"output"`
+	Includes          []string             `yaml:"includes"`
+	Terms             []string             `yaml:"terms"`
+	Policy            string               `yaml:"policy"`
+	Dangling          string               `yaml:"dangling"`
+	Shake             bool                 `yaml:"shake"`
+	Roots             []string             `yaml:"roots"`
+	Reserve           bool                 `yaml:"reserve"`
+	ReserveNames      bool                 `yaml:"reserve_names"`
+	Clean             bool                 `yaml:"clean"`
+	Explain           bool                 `yaml:"explain"`
+	Report            string               `yaml:"report"`
+	DeclaredTerms     []fileTermDefinition `yaml:"declared_terms"`
+	DryRun            bool                 `yaml:"dry_run"`
+	ExitCode          bool                 `yaml:"exit_code"`
+	Format            string               `yaml:"format"`
+	IncludeImports    bool                 `yaml:"include_imports"`
+	IncludeSourceInfo bool                 `yaml:"include_source_info"`
+	Variants          []fileVariant        `yaml:"variants"`
+}
+
+type fileTermDefinition struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+}
+
+type fileVariant struct {
+	Name   string   `yaml:"name"`
+	Terms  []string `yaml:"terms"`
+	Output string   `yaml:"output"`
+}
+
+// LoadConfigFile reads the yaml config file at path into a Config. Paths in
+// the file are relative to the working directory, just like on the command
+// line.
+//
+// The Config remembers where its values came from, so Validate can report the
+// line of an invalid value.
+func LoadConfigFile(path string) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return Config{}, fmt.Errorf("%s: %s", path, err)
+	}
+	var fc fileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&fc); err != nil && err != io.EOF {
+		return Config{}, fmt.Errorf("%s: %s", path, err)
+	}
+
+	source := &configSource{path: path, nodes: make(map[string]*yaml.Node)}
+	if len(root.Content) != 0 {
+		source.collect(root.Content[0], "")
+	}
+
+	config := Config{
+		Inputs:            fc.Inputs,
+		DescriptorSets:    fc.DescriptorSets,
+		Output:            fc.Output,
+		Includes:          fc.Includes,
+		Shake:             fc.Shake,
+		Roots:             fc.Roots,
+		Reserve:           fc.Reserve,
+		ReserveNames:      fc.ReserveNames,
+		Clean:             fc.Clean,
+		Explain:           fc.Explain,
+		Report:            fc.Report,
+		DryRun:            fc.DryRun,
+		ExitCode:          fc.ExitCode,
+		Format:            fc.Format,
+		IncludeImports:    fc.IncludeImports,
+		IncludeSourceInfo: fc.IncludeSourceInfo,
+		source:            source,
+	}
+	if len(fc.Terms) != 0 {
+		config.Terms = makeStringSet(fc.Terms)
+	}
+	if len(fc.Policy) != 0 {
+		if config.Policy, err = protofilter.ParsePolicy(fc.Policy); err != nil {
+			return Config{}, source.wrap("policy", err)
+		}
+	}
+	if len(fc.Dangling) != 0 {
+		if config.Dangling, err = protofilter.ParseDanglingMode(fc.Dangling); err != nil {
+			return Config{}, source.wrap("dangling", err)
+		}
+	}
+	for _, definition := range fc.DeclaredTerms {
+		config.DeclaredTerms = append(config.DeclaredTerms, protofilter.TermDefinition{
+			Name:        definition.Name,
+			Description: definition.Description,
+		})
+	}
+	for _, variant := range fc.Variants {
+		config.Variants = append(config.Variants, Variant{
+			Name:   variant.Name,
+			Terms:  makeStringSet(variant.Terms),
+			Output: variant.Output,
+		})
+	}
+	return config, nil
+}
+
+// configSource records the yaml node every value of a Config was read from
+type configSource struct {
+	path string
+	// nodes are keyed by their path in the document, e.g. `variants[0].terms[1]`
+	nodes map[string]*yaml.Node
+}
+
+// collect adds the node and all of its descendants to the source
+func (s *configSource) collect(node *yaml.Node, key string) {
+	if len(key) != 0 {
+		s.nodes[key] = node
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name := node.Content[i].Value
+			if len(key) != 0 {
+				name = key + "." + name
+			}
+			s.collect(node.Content[i+1], name)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			s.collect(child, key+"["+strconv.Itoa(i)+"]")
+		}
+	}
+}
+
+// wrap prefixes err with the location of the value at key. It returns err
+// unchanged if the value did not come from the config file.
+func (s *configSource) wrap(key string, err error) error {
+	if s == nil {
+		return err
+	}
+	node, ok := s.nodes[key]
+	if !ok {
+		return err
+	}
+	return fmt.Errorf("%s:%d: %s", s.path, node.Line, err)
+}
+
+// elementKey returns the key of the element of the sequence at key which has
+// the given value, or key itself if there is no such element
+func (s *configSource) elementKey(key string, value string) string {
+	if s == nil {
+		return key
+	}
+	for i := 0; ; i++ {
+		node, ok := s.nodes[key+"["+strconv.Itoa(i)+"]"]
+		if !ok {
+			return key
+		}
+		if node.Value == value {
+			return key + "[" + strconv.Itoa(i) + "]"
+		}
+	}
+}
+
+// forget removes the value at key and all values nested in it, because they
+// were overridden
+func (s *configSource) forget(key string) {
+	if s == nil {
+		return
+	}
+	for name := range s.nodes {
+		if name == key || strings.HasPrefix(name, key+"[") || strings.HasPrefix(name, key+".") {
+			delete(s.nodes, name)
+		}
+	}
+}
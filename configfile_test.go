@@ -0,0 +1,191 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wdullaer/proto-filter/protofilter"
+)
+
+// writeConfigFile is a test helper which writes the contents to a config file
+// in the directory and returns its path
+func writeConfigFile(t *testing.T, dir string, contents string) string {
+	path := filepath.Join(dir, defaultConfigFile)
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	return path
+}
+
+// tempDir is a test helper which creates a temporary directory, it returns a
+// function to remove it again
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "proto-filter")
+	require.NoError(t, err)
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Run("Should map the file onto a Config", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+		path := writeConfigFile(t, dir, `
+inputs: [test.proto]
+descriptor_sets: [api.pb]
+output: ./filtered
+includes: [., vendor]
+policy: deny
+dangling: cascade
+shake: true
+roots: [com.test.*]
+reserve_names: true
+clean: true
+explain: true
+report: report.json
+dry_run: true
+exit_code: true
+format: json
+include_imports: true
+include_source_info: true
+declared_terms:
+  - name: NA
+    description: North America
+variants:
+  - name: na
+    terms: [NA]
+    output: ./na
+`)
+		config, err := LoadConfigFile(path)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"test.proto"}, config.Inputs)
+			assert.Equal(t, []string{"api.pb"}, config.DescriptorSets)
+			assert.Equal(t, "./filtered", config.Output)
+			assert.Equal(t, []string{".", "vendor"}, config.Includes)
+			assert.Equal(t, protofilter.PolicyDeny, config.Policy)
+			assert.Equal(t, protofilter.DanglingCascade, config.Dangling)
+			assert.True(t, config.Shake)
+			assert.Equal(t, []string{"com.test.*"}, config.Roots)
+			assert.True(t, config.ReserveNames)
+			assert.True(t, config.Clean)
+			assert.True(t, config.Explain)
+			assert.Equal(t, "report.json", config.Report)
+			assert.True(t, config.DryRun)
+			assert.True(t, config.ExitCode)
+			assert.Equal(t, "json", config.Format)
+			assert.True(t, config.IncludeImports)
+			assert.True(t, config.IncludeSourceInfo)
+			assert.Equal(t, []protofilter.TermDefinition{{Name: "NA", Description: "North America"}}, config.DeclaredTerms)
+			if assert.Len(t, config.Variants, 1) {
+				assert.Equal(t, "na", config.Variants[0].Name)
+				assert.Equal(t, []interface{}{"NA"}, config.Variants[0].Terms.Flatten())
+				assert.Equal(t, "./na", config.Variants[0].Output)
+			}
+			assert.Empty(t, config.Validate())
+		}
+	})
+
+	t.Run("Should accept an empty file", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+		config, err := LoadConfigFile(writeConfigFile(t, dir, ""))
+		if assert.NoError(t, err) {
+			assert.Equal(t, []error{errNoInputs, errNoTerms}, config.Validate())
+		}
+	})
+
+	t.Run("Should return an error for unknown fields", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+		_, err := LoadConfigFile(writeConfigFile(t, dir, "inputs: [test.proto]\nterm: [NA]\n"))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "line 2: field term not found")
+		}
+	})
+
+	t.Run("Should return an error with the line of an invalid policy", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+		path := writeConfigFile(t, dir, "inputs: [test.proto]\npolicy: maybe\n")
+		_, err := LoadConfigFile(path)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), path+":2: Unknown policy")
+		}
+	})
+}
+
+func TestConfigFileValidate(t *testing.T) {
+	t.Run("Should report invalid values with their line", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+		path := writeConfigFile(t, dir, `
+inputs: [test.proto]
+roots:
+  - valid
+  - /(root/
+variants:
+  - name: na
+    terms:
+      - NA
+      - /(term/
+  - name: na
+    terms: [JP]
+  - terms: [EU]
+`)
+		config, err := LoadConfigFile(path)
+		require.NoError(t, err)
+
+		var messages []string
+		for _, err := range config.Validate() {
+			messages = append(messages, err.Error())
+		}
+		if assert.Len(t, messages, 4) {
+			assert.Contains(t, messages[0], path+":10: Invalid regular expression")
+			assert.Contains(t, messages[1], path+":11: Variant \"na\" is defined more than once")
+			assert.Contains(t, messages[2], path+":13: Variants must have a name")
+			assert.Contains(t, messages[3], path+":5: Invalid regular expression")
+		}
+	})
+
+	t.Run("Should report invalid and undeclared terms with their line", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+		path := writeConfigFile(t, dir, `
+inputs: [test.proto]
+terms:
+  - NA
+  - JP
+declared_terms:
+  - name: NA
+  - name: "EU "
+`)
+		config, err := LoadConfigFile(path)
+		require.NoError(t, err)
+
+		errs := config.Validate()
+		if assert.Len(t, errs, 1) {
+			assert.EqualError(t, errs[0], path+`:8: Invalid declared term: Term "EU " has leading or trailing whitespace`)
+		}
+		registry, err := protofilter.NewRegistry(config.DeclaredTerms[:1])
+		require.NoError(t, err)
+		errs = config.ValidateDeclaredTerms(registry)
+		if assert.Len(t, errs, 1) {
+			assert.EqualError(t, errs[0], path+`:5: Term "JP" is not declared`)
+		}
+	})
+
+	t.Run("Should not report lines of values that were overridden", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+		config, err := LoadConfigFile(writeConfigFile(t, dir, "inputs: [test.proto]\nterms: [NA]\nroots: [valid]\n"))
+		require.NoError(t, err)
+		config.Roots = []string{"/(root/"}
+		config.source.forget("roots")
+
+		errs := config.Validate()
+		if assert.Len(t, errs, 1) {
+			assert.Regexp(t, "^Invalid regular expression", errs[0].Error())
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoprint"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// diffFiles returns a unified diff between every original file, as parsed, and
+// the filtered version that would be written to the output directory. Both
+// sides are printed with the printer of the output, so only the changes made by
+// the filter show up. Files that were removed are diffed against /dev/null.
+func diffFiles(printer *protoprint.Printer, originals []*desc.FileDescriptor, filtered []*desc.FileDescriptor, output string) (string, error) {
+	byName := make(map[string]*desc.FileDescriptor, len(filtered))
+	for _, fd := range filtered {
+		byName[fd.GetName()] = fd
+	}
+
+	var result strings.Builder
+	for _, original := range originals {
+		before, err := printFile(printer, original)
+		if err != nil {
+			return "", err
+		}
+		after, toFile := "", "/dev/null"
+		if fd, ok := byName[original.GetName()]; ok {
+			if after, err = printFile(printer, fd); err != nil {
+				return "", err
+			}
+			toFile = filepath.ToSlash(filepath.Join(output, fd.GetName()))
+		}
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(before),
+			B:        difflib.SplitLines(after),
+			FromFile: original.GetName(),
+			ToFile:   toFile,
+			Context:  3,
+		})
+		if err != nil {
+			return "", err
+		}
+		result.WriteString(diff)
+	}
+	return result.String(), nil
+}
+
+func printFile(printer *protoprint.Printer, fd *desc.FileDescriptor) (string, error) {
+	var buf bytes.Buffer
+	if err := printer.PrintProtoFile(fd, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoprint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wdullaer/proto-filter/protofilter"
+)
+
+const diffTestProto = `syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+import "google/protobuf/empty.proto";
+
+message Message {
+    string name = 1;
+    google.protobuf.Empty internal = 2 [(filter.field).exclude = "NA"];
+}
+`
+
+func TestDiffFiles(t *testing.T) {
+	descs, err := protofilter.Parse([]string{"example/test.proto"}, []string{"."})
+	require.NoError(t, err)
+	printer := &protoprint.Printer{}
+
+	t.Run("Should show the lines removed by the filter", func(t *testing.T) {
+		result, err := protofilter.Filter(descs, protofilter.Options{Terms: []string{"NA"}})
+		require.NoError(t, err)
+
+		diff, err := diffFiles(printer, descs, result.Files, "output")
+		if assert.NoError(t, err) {
+			assert.Contains(t, diff, "--- example/test.proto\n+++ output/example/test.proto\n")
+			assert.Contains(t, diff, "\n-  string jp_string = 1")
+			assert.NotRegexp(t, `\n\+.*jp_string`, diff)
+		}
+	})
+
+	t.Run("Should show the imports removed by the filter", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "diff.proto"), []byte(diffTestProto), 0644))
+		imports, err := protofilter.Parse([]string{"diff.proto"}, []string{dir, "."})
+		require.NoError(t, err)
+		result, err := protofilter.Filter(imports, protofilter.Options{Terms: []string{"NA"}})
+		require.NoError(t, err)
+
+		diff, err := diffFiles(printer, imports, result.Files, "output")
+		if assert.NoError(t, err) {
+			assert.Contains(t, diff, "\n-import \"google/protobuf/empty.proto\";\n")
+		}
+	})
+
+	t.Run("Should return an empty diff if nothing changed", func(t *testing.T) {
+		diff, err := diffFiles(printer, descs, descs, "output")
+		if assert.NoError(t, err) {
+			assert.Empty(t, diff)
+		}
+	})
+
+	t.Run("Should diff a removed file against /dev/null", func(t *testing.T) {
+		diff, err := diffFiles(printer, descs, []*desc.FileDescriptor{}, "output")
+		if assert.NoError(t, err) {
+			assert.Contains(t, diff, "--- example/test.proto\n+++ /dev/null\n")
+		}
+	})
+}
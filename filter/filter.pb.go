@@ -24,6 +24,7 @@ const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 type ValueFilter struct {
 	Include              []string `protobuf:"bytes,1,rep,name=include" json:"include,omitempty"`
 	Exclude              []string `protobuf:"bytes,2,rep,name=exclude" json:"exclude,omitempty"`
+	Expression           *string  `protobuf:"bytes,3,opt,name=expression" json:"expression,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -68,6 +69,101 @@ func (m *ValueFilter) GetExclude() []string {
 	return nil
 }
 
+func (m *ValueFilter) GetExpression() string {
+	if m != nil && m.Expression != nil {
+		return *m.Expression
+	}
+	return ""
+}
+
+// TermRegistry declares the terms that may be used in annotations and given to
+// the filter. Once a project declares its terms, unknown terms are rejected.
+type TermRegistry struct {
+	Term                 []*TermDefinition `protobuf:"bytes,1,rep,name=term" json:"term,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *TermRegistry) Reset()         { *m = TermRegistry{} }
+func (m *TermRegistry) String() string { return proto.CompactTextString(m) }
+func (*TermRegistry) ProtoMessage()    {}
+func (*TermRegistry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1f5303cab7a20d6f, []int{1}
+}
+
+func (m *TermRegistry) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TermRegistry.Unmarshal(m, b)
+}
+func (m *TermRegistry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TermRegistry.Marshal(b, m, deterministic)
+}
+func (m *TermRegistry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TermRegistry.Merge(m, src)
+}
+func (m *TermRegistry) XXX_Size() int {
+	return xxx_messageInfo_TermRegistry.Size(m)
+}
+func (m *TermRegistry) XXX_DiscardUnknown() {
+	xxx_messageInfo_TermRegistry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TermRegistry proto.InternalMessageInfo
+
+func (m *TermRegistry) GetTerm() []*TermDefinition {
+	if m != nil {
+		return m.Term
+	}
+	return nil
+}
+
+type TermDefinition struct {
+	Name                 *string  `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Description          *string  `protobuf:"bytes,2,opt,name=description" json:"description,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TermDefinition) Reset()         { *m = TermDefinition{} }
+func (m *TermDefinition) String() string { return proto.CompactTextString(m) }
+func (*TermDefinition) ProtoMessage()    {}
+func (*TermDefinition) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1f5303cab7a20d6f, []int{2}
+}
+
+func (m *TermDefinition) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TermDefinition.Unmarshal(m, b)
+}
+func (m *TermDefinition) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TermDefinition.Marshal(b, m, deterministic)
+}
+func (m *TermDefinition) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TermDefinition.Merge(m, src)
+}
+func (m *TermDefinition) XXX_Size() int {
+	return xxx_messageInfo_TermDefinition.Size(m)
+}
+func (m *TermDefinition) XXX_DiscardUnknown() {
+	xxx_messageInfo_TermDefinition.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TermDefinition proto.InternalMessageInfo
+
+func (m *TermDefinition) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *TermDefinition) GetDescription() string {
+	if m != nil && m.Description != nil {
+		return *m.Description
+	}
+	return ""
+}
+
 var E_File = &proto.ExtensionDesc{
 	ExtendedType:  (*descriptor.FileOptions)(nil),
 	ExtensionType: (*ValueFilter)(nil),
@@ -77,6 +173,15 @@ var E_File = &proto.ExtensionDesc{
 	Filename:      "filter.proto",
 }
 
+var E_Terms = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FileOptions)(nil),
+	ExtensionType: (*TermRegistry)(nil),
+	Field:         61256,
+	Name:          "filter.terms",
+	Tag:           "bytes,61256,opt,name=terms",
+	Filename:      "filter.proto",
+}
+
 var E_Service = &proto.ExtensionDesc{
 	ExtendedType:  (*descriptor.ServiceOptions)(nil),
 	ExtensionType: (*ValueFilter)(nil),
@@ -142,7 +247,10 @@ var E_OneOf = &proto.ExtensionDesc{
 
 func init() {
 	proto.RegisterType((*ValueFilter)(nil), "filter.ValueFilter")
+	proto.RegisterType((*TermRegistry)(nil), "filter.TermRegistry")
+	proto.RegisterType((*TermDefinition)(nil), "filter.TermDefinition")
 	proto.RegisterExtension(E_File)
+	proto.RegisterExtension(E_Terms)
 	proto.RegisterExtension(E_Service)
 	proto.RegisterExtension(E_Method)
 	proto.RegisterExtension(E_Enum)
@@ -155,24 +263,30 @@ func init() {
 func init() { proto.RegisterFile("filter.proto", fileDescriptor_1f5303cab7a20d6f) }
 
 var fileDescriptor_1f5303cab7a20d6f = []byte{
-	// 293 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x91, 0xcd, 0x4a, 0xc3, 0x40,
-	0x10, 0xc7, 0xa9, 0xd5, 0x94, 0x4e, 0x3d, 0xad, 0x97, 0x20, 0x7e, 0x44, 0x4f, 0x3d, 0xa5, 0xe0,
-	0x71, 0x6f, 0x1e, 0xac, 0xa0, 0x84, 0x48, 0x05, 0x3d, 0x96, 0x9a, 0xcc, 0xc6, 0x85, 0xcd, 0x4e,
-	0xc8, 0x47, 0xf1, 0x0d, 0x7d, 0x0b, 0x9f, 0x45, 0x36, 0x9b, 0x40, 0xa1, 0x7b, 0xd8, 0x53, 0x98,
-	0xfc, 0x66, 0x7e, 0x93, 0xf9, 0x07, 0xce, 0x85, 0x54, 0x2d, 0xd6, 0x71, 0x55, 0x53, 0x4b, 0x2c,
-	0xb0, 0xd5, 0x65, 0x54, 0x10, 0x15, 0x0a, 0x57, 0xfd, 0xdb, 0xaf, 0x4e, 0xac, 0x72, 0x6c, 0xb2,
-	0x5a, 0x56, 0x2d, 0x0d, 0x9d, 0xf7, 0x8f, 0xb0, 0xf8, 0xd8, 0xa9, 0x0e, 0xd7, 0xfd, 0x00, 0x0b,
-	0x61, 0x26, 0x75, 0xa6, 0xba, 0x1c, 0xc3, 0x49, 0x34, 0x5d, 0xce, 0x37, 0x63, 0x69, 0x08, 0xfe,
-	0x58, 0x72, 0x62, 0xc9, 0x50, 0xf2, 0x67, 0x38, 0x15, 0x52, 0x21, 0xbb, 0x8a, 0xed, 0xb6, 0x78,
-	0xdc, 0x16, 0xaf, 0xa5, 0xc2, 0xb4, 0x6a, 0x25, 0xe9, 0x26, 0xfc, 0xfd, 0x9b, 0x46, 0x93, 0xe5,
-	0xe2, 0xe1, 0x22, 0x1e, 0xbe, 0xf4, 0x60, 0xed, 0xa6, 0x17, 0xf0, 0x37, 0x98, 0x35, 0x58, 0xef,
-	0x65, 0x86, 0xec, 0xf6, 0xc8, 0xf5, 0x6e, 0x89, 0x97, 0x6e, 0xd4, 0xf0, 0x04, 0x82, 0x12, 0xdb,
-	0x6f, 0xca, 0xd9, 0xcd, 0x91, 0x30, 0xe9, 0x81, 0x97, 0x6f, 0x90, 0x98, 0x4b, 0x51, 0x77, 0xa5,
-	0xe3, 0xd2, 0x27, 0xdd, 0x95, 0x7e, 0x97, 0x1a, 0x01, 0xff, 0x04, 0x30, 0xcf, 0xed, 0xde, 0x10,
-	0x76, 0xe7, 0xd4, 0xf5, 0x53, 0x5e, 0xce, 0x39, 0x8e, 0xed, 0x26, 0xc2, 0x12, 0x9b, 0x66, 0x57,
-	0xb8, 0x22, 0x4c, 0x2c, 0xf1, 0x8b, 0x70, 0xd0, 0xf0, 0x17, 0x38, 0x13, 0x12, 0x55, 0xce, 0xae,
-	0x1d, 0xbf, 0x17, 0x95, 0x5f, 0x80, 0x56, 0xc1, 0x5f, 0x21, 0x20, 0x8d, 0x5b, 0x12, 0x0e, 0x59,
-	0xaa, 0x91, 0x84, 0x9f, 0x8c, 0x34, 0xa6, 0xe2, 0x3f, 0x00, 0x00, 0xff, 0xff, 0x7c, 0x8c, 0xf4,
-	0x8d, 0xf2, 0x02, 0x00, 0x00,
+	// 389 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x91, 0xcf, 0x6a, 0xe3, 0x30,
+	0x10, 0xc6, 0xc9, 0x9f, 0x4d, 0xc8, 0x38, 0xec, 0x41, 0xbb, 0x2c, 0x66, 0xd9, 0xcd, 0x7a, 0x73,
+	0x0a, 0x7b, 0x70, 0x20, 0x47, 0x9f, 0xb7, 0x29, 0xb4, 0x84, 0x14, 0xb7, 0xb4, 0xc7, 0xe0, 0xc6,
+	0x23, 0x57, 0x20, 0x4b, 0x41, 0xb2, 0x43, 0xfa, 0x84, 0xed, 0x53, 0xf4, 0x59, 0x8a, 0x24, 0x1b,
+	0x5c, 0x62, 0xa8, 0x4f, 0xf6, 0xcc, 0x37, 0xfa, 0xe9, 0x9b, 0x4f, 0x30, 0xa5, 0x8c, 0x17, 0xa8,
+	0xc2, 0x83, 0x92, 0x85, 0x24, 0x23, 0x57, 0xfd, 0x0c, 0x32, 0x29, 0x33, 0x8e, 0x4b, 0xdb, 0x7d,
+	0x2c, 0xe9, 0x32, 0x45, 0xbd, 0x57, 0xec, 0x50, 0xc8, 0x6a, 0x72, 0x9e, 0x80, 0x77, 0x9f, 0xf0,
+	0x12, 0xd7, 0xf6, 0x00, 0xf1, 0x61, 0xcc, 0xc4, 0x9e, 0x97, 0x29, 0xfa, 0xbd, 0x60, 0xb0, 0x98,
+	0xc4, 0x75, 0x69, 0x14, 0x3c, 0x39, 0xa5, 0xef, 0x94, 0xaa, 0x24, 0x33, 0x00, 0x3c, 0x1d, 0x14,
+	0x6a, 0xcd, 0xa4, 0xf0, 0x07, 0x41, 0x6f, 0x31, 0x89, 0x1b, 0x9d, 0x79, 0x04, 0xd3, 0x3b, 0x54,
+	0x79, 0x8c, 0x19, 0xd3, 0x85, 0x7a, 0x26, 0xff, 0x60, 0x58, 0xa0, 0xca, 0xed, 0x05, 0xde, 0xea,
+	0x47, 0x58, 0x39, 0x37, 0x33, 0xff, 0x91, 0x32, 0xc1, 0x0a, 0x26, 0x45, 0x6c, 0x67, 0xe6, 0x6b,
+	0xf8, 0xfa, 0xb1, 0x4f, 0x08, 0x0c, 0x45, 0x92, 0x1b, 0x7b, 0xe6, 0x1e, 0xfb, 0x4f, 0x02, 0xf0,
+	0xea, 0xc5, 0x8c, 0x85, 0xbe, 0x95, 0x9a, 0xad, 0xe8, 0x12, 0x86, 0x94, 0x71, 0x24, 0xbf, 0x42,
+	0x97, 0x48, 0x58, 0x27, 0x12, 0xae, 0x19, 0xc7, 0xad, 0x1d, 0xd2, 0xfe, 0xcb, 0x9b, 0x71, 0xef,
+	0xad, 0xbe, 0xd5, 0x9e, 0x1a, 0xd1, 0xc4, 0x16, 0x10, 0x5d, 0xc1, 0x17, 0x63, 0x4c, 0x7f, 0x42,
+	0x7a, 0xad, 0x48, 0xdf, 0x9b, 0xdb, 0xd5, 0x09, 0xc4, 0x0e, 0x11, 0xdd, 0xc0, 0x58, 0xa3, 0x3a,
+	0xb2, 0x3d, 0x92, 0x3f, 0x67, 0xb4, 0x5b, 0xa7, 0x74, 0xb2, 0x56, 0x63, 0xa2, 0x0d, 0x8c, 0x72,
+	0x2c, 0x9e, 0x64, 0x4a, 0x66, 0x67, 0xc0, 0x8d, 0x15, 0x3a, 0xf1, 0x2a, 0x88, 0x49, 0x0d, 0x45,
+	0x99, 0xb7, 0xec, 0x7a, 0x21, 0xca, 0xbc, 0x5b, 0x6a, 0x06, 0x10, 0x3d, 0x00, 0x98, 0xef, 0xee,
+	0x68, 0x14, 0xf2, 0xb7, 0x15, 0x67, 0x4f, 0x75, 0x62, 0x4e, 0xb0, 0x1e, 0x37, 0x11, 0xe6, 0xa8,
+	0x75, 0x92, 0xb5, 0x45, 0xb8, 0x71, 0x4a, 0xb7, 0x08, 0x2b, 0x8c, 0x79, 0x60, 0xca, 0x90, 0xa7,
+	0xe4, 0x77, 0xcb, 0x03, 0x23, 0xef, 0x16, 0xa0, 0x43, 0x44, 0xd7, 0x30, 0x92, 0x02, 0x77, 0x92,
+	0xb6, 0xc0, 0xb6, 0x02, 0x25, 0xed, 0x06, 0x93, 0x02, 0xb7, 0xf4, 0x7d, 0x00, 0xa1, 0x7a, 0xc5,
+	0x01, 0xe2, 0x03, 0x00, 0x00,
 }
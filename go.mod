@@ -6,7 +6,10 @@ require (
 	github.com/Workiva/go-datastructures v1.0.50
 	github.com/golang/protobuf v1.3.1
 	github.com/jhump/protoreflect v1.5.0
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/stretchr/testify v1.4.0
 	github.com/urfave/cli/v2 v2.0.0
 	github.com/workiva/go-datastructures v1.0.50 // indirect
+	google.golang.org/grpc v1.8.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,49 @@
+// Package grpcfilter applies the filter annotations to running gRPC servers:
+// ReflectionProxy hides elements from server reflection, and MethodGuard
+// rejects calls to hidden methods. The audience of a call is identified by its
+// terms, which a TermsFunc resolves from the context of the call:
+//
+//	terms := grpcfilter.TermsFromMetadata("x-proto-filter-terms", nil)
+//	guard := grpcfilter.NewMethodGuard(files, terms, grpcfilter.GuardOptions{})
+//	server := grpc.NewServer(grpc.UnaryInterceptor(guard.UnaryServerInterceptor()))
+package grpcfilter
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TermsFunc returns the terms to filter for, for the caller of a gRPC call.
+// An error returned by the function is returned to the caller, so it should be
+// a status error.
+type TermsFunc func(ctx context.Context) ([]string, error)
+
+// TermsFromMetadata returns a TermsFunc which reads the terms from the
+// metadata key of the call. Every value of the key can hold several terms,
+// separated by commas. Calls without the key are filtered for the defaults,
+// or rejected with codes.InvalidArgument if there are none.
+func TermsFromMetadata(key string, defaults []string) TermsFunc {
+	key = strings.ToLower(key)
+	return func(ctx context.Context) ([]string, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		var terms []string
+		for _, value := range md[key] {
+			for _, term := range strings.Split(value, ",") {
+				if term = strings.TrimSpace(term); len(term) != 0 {
+					terms = append(terms, term)
+				}
+			}
+		}
+		if len(terms) != 0 {
+			return terms, nil
+		}
+		if len(defaults) != 0 {
+			return defaults, nil
+		}
+		return nil, status.Errorf(codes.InvalidArgument, "No terms given in metadata key %q", key)
+	}
+}
@@ -0,0 +1,54 @@
+package grpcfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTermsFromMetadata(t *testing.T) {
+	cases := []struct {
+		name     string
+		md       metadata.MD
+		defaults []string
+		output   []string
+	}{
+		{
+			name:   "Should split the values on commas",
+			md:     metadata.Pairs("x-terms", "NA, JP", "x-terms", "partner.foo"),
+			output: []string{"NA", "JP", "partner.foo"},
+		},
+		{
+			name:     "Should return the defaults without the key",
+			md:       metadata.Pairs("other", "NA"),
+			defaults: []string{"public"},
+			output:   []string{"public"},
+		},
+		{
+			name:     "Should return the defaults if the key is empty",
+			md:       metadata.Pairs("x-terms", " , "),
+			defaults: []string{"public"},
+			output:   []string{"public"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+			if terms, err := TermsFromMetadata("X-Terms", tc.defaults)(ctx); assert.NoError(t, err) {
+				assert.Equal(t, tc.output, terms)
+			}
+		})
+	}
+
+	t.Run("Should return InvalidArgument without terms or defaults", func(t *testing.T) {
+		_, err := TermsFromMetadata("x-terms", nil)(context.Background())
+		if s, ok := status.FromError(err); assert.True(t, ok) {
+			assert.Equal(t, codes.InvalidArgument, s.Code())
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package grpcfilter
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/wdullaer/proto-filter/protofilter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GuardOptions determine how a MethodGuard decides about calls
+type GuardOptions struct {
+	// Filter are the options the methods are evaluated with. Its Terms are
+	// replaced by the terms of the caller.
+	Filter protofilter.Options
+	// Code is the code hidden calls are rejected with, codes.Unimplemented if
+	// it is codes.OK
+	Code codes.Code
+}
+
+// MethodGuard rejects calls to the methods that are hidden from the caller by
+// the filter annotations of the method, its service and its file, so clients
+// can not call a method they would not find in their filtered files.
+type MethodGuard struct {
+	methods map[string]*desc.MethodDescriptor
+	terms   TermsFunc
+	options GuardOptions
+
+	// checkers caches the visibility checker of every term set, keyed by the
+	// terms joined with a null character
+	mutex    sync.Mutex
+	checkers map[string]*protofilter.VisibilityChecker
+}
+
+// NewMethodGuard returns a guard for the methods of the services in the files.
+// The terms of a caller are resolved with terms. Methods that are not part of
+// the files are not checked.
+func NewMethodGuard(files []*desc.FileDescriptor, terms TermsFunc, options GuardOptions) *MethodGuard {
+	if options.Code == codes.OK {
+		options.Code = codes.Unimplemented
+	}
+	methods := make(map[string]*desc.MethodDescriptor)
+	for _, fd := range files {
+		for _, service := range fd.GetServices() {
+			for _, method := range service.GetMethods() {
+				methods["/"+service.GetFullyQualifiedName()+"/"+method.GetName()] = method
+			}
+		}
+	}
+	return &MethodGuard{
+		methods:  methods,
+		terms:    terms,
+		options:  options,
+		checkers: make(map[string]*protofilter.VisibilityChecker),
+	}
+}
+
+// UnaryServerInterceptor returns an interceptor which rejects unary calls to
+// hidden methods
+func (g *MethodGuard) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := g.Check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor which rejects streaming calls
+// to hidden methods
+func (g *MethodGuard) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := g.Check(stream.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+// Check returns an error if the method, in the form `/package.Service/Method`,
+// is hidden from the caller. The error has the message gRPC uses for unknown
+// services and methods, so it does not reveal that the method exists.
+func (g *MethodGuard) Check(ctx context.Context, fullMethod string) error {
+	method, ok := g.methods[fullMethod]
+	if !ok {
+		return nil
+	}
+	terms, err := g.terms(ctx)
+	if err != nil {
+		return err
+	}
+	checker, err := g.checker(terms)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if visible, err := checker.Visible(method); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	} else if visible {
+		return nil
+	}
+	if visible, err := checker.Visible(method.GetService()); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	} else if !visible {
+		return status.Errorf(g.options.Code, "unknown service %s", method.GetService().GetFullyQualifiedName())
+	}
+	return status.Errorf(g.options.Code, "unknown method %s", method.GetName())
+}
+
+// maxCheckers is the number of term sets MethodGuard caches a checker for.
+// The terms come from the callers, so the cache is emptied when it is full.
+const maxCheckers = 1024
+
+// checker returns the visibility checker for the terms, creating it on first
+// use. Terms that are invalid are not cached.
+func (g *MethodGuard) checker(terms []string) (*protofilter.VisibilityChecker, error) {
+	key := strings.Join(terms, "\x00")
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if checker, ok := g.checkers[key]; ok {
+		return checker, nil
+	}
+	options := g.options.Filter
+	options.Terms = terms
+	checker, err := protofilter.NewVisibilityChecker(options)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.checkers) >= maxCheckers {
+		g.checkers = make(map[string]*protofilter.VisibilityChecker)
+	}
+	g.checkers[key] = checker
+	return checker, nil
+}
@@ -0,0 +1,158 @@
+package grpcfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wdullaer/proto-filter/protofilter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testServerStream is a grpc.ServerStream which only has a context
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// statusCode is a test helper which returns the code of a status error, or
+// codes.Unknown for other errors
+func statusCode(err error) codes.Code {
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+	return codes.Unknown
+}
+
+// withTerms is a test helper which returns an incoming context with the terms
+// in its metadata
+func withTerms(terms string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-proto-filter-terms", terms))
+}
+
+func TestMethodGuard(t *testing.T) {
+	files := parseTestFiles(t, "api.proto")
+	terms := TermsFromMetadata("x-proto-filter-terms", nil)
+
+	cases := []struct {
+		name    string
+		terms   string
+		method  string
+		options GuardOptions
+		code    codes.Code
+		message string
+	}{
+		{
+			name:   "Should allow visible methods",
+			terms:  "partner",
+			method: "/test.Public/Get",
+			code:   codes.OK,
+		},
+		{
+			name:    "Should reject hidden methods",
+			terms:   "partner",
+			method:  "/test.Public/Hidden",
+			code:    codes.Unimplemented,
+			message: "unknown method Hidden",
+		},
+		{
+			name:    "Should reject the methods of hidden services",
+			terms:   "partner",
+			method:  "/test.Admin/Reset",
+			code:    codes.Unimplemented,
+			message: "unknown service test.Admin",
+		},
+		{
+			name:   "Should allow methods included for the terms",
+			terms:  "internal",
+			method: "/test.Public/Hidden",
+			code:   codes.OK,
+		},
+		{
+			name:   "Should allow methods which are not part of the files",
+			terms:  "partner",
+			method: "/grpc.health.v1.Health/Check",
+			code:   codes.OK,
+		},
+		{
+			name:    "Should apply the filter options",
+			terms:   "partner",
+			method:  "/test.Public/Get",
+			options: GuardOptions{Filter: protofilter.Options{Policy: protofilter.PolicyDeny}},
+			code:    codes.Unimplemented,
+			message: "unknown service test.Public",
+		},
+		{
+			name:    "Should reject hidden methods with the configured code",
+			terms:   "partner",
+			method:  "/test.Public/Hidden",
+			options: GuardOptions{Code: codes.PermissionDenied},
+			code:    codes.PermissionDenied,
+			message: "unknown method Hidden",
+		},
+		{
+			name:   "Should reject invalid terms",
+			terms:  "/[/",
+			method: "/test.Public/Get",
+			code:   codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			guard := NewMethodGuard(files, terms, tc.options)
+			called := false
+
+			_, err := guard.UnaryServerInterceptor()(withTerms(tc.terms), nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, func(context.Context, interface{}) (interface{}, error) {
+				called = true
+				return nil, nil
+			})
+			s, _ := status.FromError(err)
+			assert.Equal(t, tc.code, statusCode(err))
+			assert.Equal(t, tc.code == codes.OK, called)
+			if len(tc.message) != 0 {
+				assert.Equal(t, tc.message, s.Message())
+			}
+		})
+	}
+
+	t.Run("Should reject streaming calls to hidden methods", func(t *testing.T) {
+		guard := NewMethodGuard(files, terms, GuardOptions{})
+		interceptor := guard.StreamServerInterceptor()
+		handler := func(interface{}, grpc.ServerStream) error { return nil }
+
+		err := interceptor(nil, &testServerStream{ctx: withTerms("partner")}, &grpc.StreamServerInfo{FullMethod: "/test.Public/Hidden"}, handler)
+		assert.Equal(t, codes.Unimplemented, statusCode(err))
+		err = interceptor(nil, &testServerStream{ctx: withTerms("internal")}, &grpc.StreamServerInfo{FullMethod: "/test.Public/Hidden"}, handler)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should create the checker of a term set once", func(t *testing.T) {
+		guard := NewMethodGuard(files, terms, GuardOptions{})
+
+		assert.NoError(t, guard.Check(withTerms("partner"), "/test.Public/Get"))
+		checker := guard.checkers["partner"]
+		require.NotNil(t, checker)
+		assert.Error(t, guard.Check(withTerms("partner"), "/test.Public/Hidden"))
+		assert.NoError(t, guard.Check(withTerms("internal"), "/test.Public/Hidden"))
+		assert.Error(t, guard.Check(withTerms("/[/"), "/test.Public/Get"))
+
+		assert.Len(t, guard.checkers, 2)
+		assert.Same(t, checker, guard.checkers["partner"])
+	})
+
+	t.Run("Should return the error of the terms function", func(t *testing.T) {
+		guard := NewMethodGuard(files, terms, GuardOptions{})
+
+		err := guard.Check(context.Background(), "/test.Public/Get")
+		assert.Equal(t, codes.InvalidArgument, statusCode(err))
+	})
+}
@@ -0,0 +1,359 @@
+package grpcfilter
+
+import (
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"github.com/wdullaer/proto-filter/protofilter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/grpclog"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+// ReflectionProxy is a gRPC server reflection service which answers from an
+// upstream reflection service. Every file it returns is filtered for the terms
+// of the client, so hidden services, methods and types can not be discovered
+// through reflection.
+type ReflectionProxy struct {
+	upstream rpb.ServerReflectionClient
+	terms    TermsFunc
+	options  protofilter.Options
+}
+
+// NewReflectionProxy returns a proxy for the upstream reflection service. The
+// files are filtered with the options, with the Terms replaced by the terms
+// returned by terms for the client. Elements that refer to a hidden type are
+// hidden as well, so the Dangling option is always DanglingCascade.
+func NewReflectionProxy(upstream rpb.ServerReflectionClient, terms TermsFunc, options protofilter.Options) *ReflectionProxy {
+	options.Dangling = protofilter.DanglingCascade
+	return &ReflectionProxy{upstream: upstream, terms: terms, options: options}
+}
+
+// Register registers the proxy as the reflection service of the server
+func (p *ReflectionProxy) Register(server *grpc.Server) {
+	rpb.RegisterServerReflectionServer(server, p)
+}
+
+// ServerReflectionInfo implements the reflection service. Every stream is
+// answered from its own upstream stream, and filtered for the terms of its
+// client.
+func (p *ReflectionProxy) ServerReflectionInfo(stream rpb.ServerReflection_ServerReflectionInfoServer) error {
+	terms, err := p.terms(stream.Context())
+	if err != nil {
+		return err
+	}
+	if _, err := protofilter.NewAnnotationRule(terms); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	options := p.options
+	options.Terms = terms
+
+	client := grpcreflect.NewClient(stream.Context(), p.upstream)
+	defer client.Reset()
+	session := &reflectionSession{
+		client:  client,
+		options: options,
+		loaded:  make(map[string]struct{}),
+		sent:    make(map[string]struct{}),
+	}
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := stream.Send(session.respond(request)); err != nil {
+			return err
+		}
+	}
+}
+
+// reflectionSession answers the requests of a single stream. It filters all
+// files it has loaded from the upstream together, so the result for a file
+// does not depend on the order of the requests.
+type reflectionSession struct {
+	client  *grpcreflect.Client
+	options protofilter.Options
+	// files are the loaded files to filter, dependencies first
+	files  []*desc.FileDescriptor
+	loaded map[string]struct{}
+	// filtered holds the filtered files by name, and the library files as they
+	// are. Files which were removed are missing.
+	filtered map[string]*desc.FileDescriptor
+	// sent are the files that were sent to the client already
+	sent map[string]struct{}
+	// services are the names of the services of the upstream
+	services []string
+}
+
+// respond answers the request. Errors are returned as an ErrorResponse.
+func (s *reflectionSession) respond(request *rpb.ServerReflectionRequest) *rpb.ServerReflectionResponse {
+	response := &rpb.ServerReflectionResponse{
+		ValidHost:       request.GetHost(),
+		OriginalRequest: request,
+	}
+	var err error
+	switch r := request.GetMessageRequest().(type) {
+	case *rpb.ServerReflectionRequest_FileByFilename:
+		var files *rpb.FileDescriptorResponse
+		files, err = s.fileByFilename(r.FileByFilename)
+		response.MessageResponse = &rpb.ServerReflectionResponse_FileDescriptorResponse{FileDescriptorResponse: files}
+	case *rpb.ServerReflectionRequest_FileContainingSymbol:
+		var files *rpb.FileDescriptorResponse
+		files, err = s.fileContainingSymbol(r.FileContainingSymbol)
+		response.MessageResponse = &rpb.ServerReflectionResponse_FileDescriptorResponse{FileDescriptorResponse: files}
+	case *rpb.ServerReflectionRequest_FileContainingExtension:
+		var files *rpb.FileDescriptorResponse
+		files, err = s.fileContainingExtension(r.FileContainingExtension.GetContainingType(), r.FileContainingExtension.GetExtensionNumber())
+		response.MessageResponse = &rpb.ServerReflectionResponse_FileDescriptorResponse{FileDescriptorResponse: files}
+	case *rpb.ServerReflectionRequest_AllExtensionNumbersOfType:
+		var numbers *rpb.ExtensionNumberResponse
+		numbers, err = s.allExtensionNumbersOfType(r.AllExtensionNumbersOfType)
+		response.MessageResponse = &rpb.ServerReflectionResponse_AllExtensionNumbersResponse{AllExtensionNumbersResponse: numbers}
+	case *rpb.ServerReflectionRequest_ListServices:
+		var services *rpb.ListServiceResponse
+		services, err = s.listServices()
+		response.MessageResponse = &rpb.ServerReflectionResponse_ListServicesResponse{ListServicesResponse: services}
+	default:
+		err = status.Errorf(codes.InvalidArgument, "Invalid MessageRequest: %v", request.GetMessageRequest())
+	}
+	if err != nil {
+		response.MessageResponse = errorResponse(err)
+	}
+	return response
+}
+
+func (s *reflectionSession) fileByFilename(name string) (*rpb.FileDescriptorResponse, error) {
+	fd, err := s.client.FileByFilename(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.load(fd); err != nil {
+		return nil, err
+	}
+	filtered, ok := s.filtered[fd.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "File not found: %s", name)
+	}
+	return s.fileResponse(filtered)
+}
+
+func (s *reflectionSession) fileContainingSymbol(symbol string) (*rpb.FileDescriptorResponse, error) {
+	fd, err := s.client.FileContainingSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.load(fd); err != nil {
+		return nil, err
+	}
+	filtered, ok := s.filtered[fd.GetName()]
+	if !ok || filtered.FindSymbol(symbol) == nil {
+		return nil, status.Errorf(codes.NotFound, "Symbol not found: %s", symbol)
+	}
+	return s.fileResponse(filtered)
+}
+
+func (s *reflectionSession) fileContainingExtension(extendee string, number int32) (*rpb.FileDescriptorResponse, error) {
+	filtered, err := s.findExtension(extendee, number)
+	if err != nil {
+		return nil, err
+	}
+	if filtered == nil {
+		return nil, status.Errorf(codes.NotFound, "Extension not found: %s(%d)", extendee, number)
+	}
+	return s.fileResponse(filtered)
+}
+
+func (s *reflectionSession) allExtensionNumbersOfType(extendee string) (*rpb.ExtensionNumberResponse, error) {
+	fd, err := s.client.FileContainingSymbol(extendee)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.load(fd); err != nil {
+		return nil, err
+	}
+	if filtered, ok := s.filtered[fd.GetName()]; !ok || filtered.FindMessage(extendee) == nil {
+		return nil, status.Errorf(codes.NotFound, "Type not found: %s", extendee)
+	}
+
+	numbers, err := s.client.AllExtensionNumbersForType(extendee)
+	if err != nil {
+		return nil, err
+	}
+	response := &rpb.ExtensionNumberResponse{BaseTypeName: extendee}
+	for _, number := range numbers {
+		filtered, err := s.findExtension(extendee, number)
+		if err != nil {
+			return nil, err
+		}
+		if filtered != nil {
+			response.ExtensionNumber = append(response.ExtensionNumber, number)
+		}
+	}
+	return response, nil
+}
+
+func (s *reflectionSession) listServices() (*rpb.ListServiceResponse, error) {
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	response := &rpb.ListServiceResponse{}
+	for _, name := range s.services {
+		fd, err := s.client.FileContainingSymbol(name)
+		if err != nil {
+			return nil, err
+		}
+		if filtered, ok := s.filtered[fd.GetName()]; ok && filtered.FindService(name) != nil {
+			response.Service = append(response.Service, &rpb.ServiceResponse{Name: name})
+		}
+	}
+	return response, nil
+}
+
+// findExtension returns the filtered file which declares the extension, or
+// nil if the extension was removed
+func (s *reflectionSession) findExtension(extendee string, number int32) (*desc.FileDescriptor, error) {
+	fd, err := s.client.FileContainingExtension(extendee, number)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.load(fd); err != nil {
+		return nil, err
+	}
+	filtered, ok := s.filtered[fd.GetName()]
+	if !ok {
+		return nil, nil
+	}
+	extensions := append([]*desc.FieldDescriptor(nil), filtered.GetExtensions()...)
+	for messages := append([]*desc.MessageDescriptor(nil), filtered.GetMessageTypes()...); len(messages) != 0; messages = messages[1:] {
+		extensions = append(extensions, messages[0].GetNestedExtensions()...)
+		messages = append(messages, messages[0].GetNestedMessageTypes()...)
+	}
+	for _, extension := range extensions {
+		if extension.GetOwner().GetFullyQualifiedName() == extendee && extension.GetNumber() == number {
+			return filtered, nil
+		}
+	}
+	return nil, nil
+}
+
+// init loads the files of all services of the upstream, so references
+// between them are taken into account from the first answer on
+func (s *reflectionSession) init() error {
+	if s.filtered != nil {
+		return nil
+	}
+	services, err := s.client.ListServices()
+	if err != nil {
+		return err
+	}
+	for _, name := range services {
+		fd, err := s.client.FileContainingSymbol(name)
+		if err != nil {
+			return err
+		}
+		s.add(fd)
+	}
+	s.services = services
+	return s.filter()
+}
+
+// load adds the file and its dependencies to the session, and filters the
+// files again if any of them is new
+func (s *reflectionSession) load(fd *desc.FileDescriptor) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+	if _, ok := s.loaded[fd.GetName()]; ok {
+		return nil
+	}
+	s.add(fd)
+	return s.filter()
+}
+
+// add adds the file and its dependencies to the files, unless they were added
+// already
+func (s *reflectionSession) add(fd *desc.FileDescriptor) {
+	if _, ok := s.loaded[fd.GetName()]; ok {
+		return
+	}
+	s.loaded[fd.GetName()] = struct{}{}
+	for _, dep := range fd.GetDependencies() {
+		s.add(dep)
+	}
+	s.files = append(s.files, fd)
+}
+
+// filter filters the loaded files. Library files are never filtered. The
+// error of the filter can name hidden elements, so it is only logged.
+func (s *reflectionSession) filter() error {
+	filtered := make(map[string]*desc.FileDescriptor, len(s.files))
+	var files []*desc.FileDescriptor
+	for _, fd := range s.files {
+		if protofilter.IsLibraryFile(fd.GetName()) {
+			filtered[fd.GetName()] = fd
+		} else {
+			files = append(files, fd)
+		}
+	}
+	result, err := protofilter.Filter(files, s.options)
+	if err != nil {
+		grpclog.Errorf("proto-filter: Failed to filter the reflection files: %v", err)
+		return status.Error(codes.Internal, "Failed to filter the files")
+	}
+	for _, fd := range result.Files {
+		filtered[fd.GetName()] = fd
+	}
+	s.filtered = filtered
+	return nil
+}
+
+// fileResponse returns the file and the dependencies which have not been sent
+// to the client yet. The dependencies of the filtered files are the original
+// files, so they are replaced with their filtered versions.
+func (s *reflectionSession) fileResponse(fd *desc.FileDescriptor) (*rpb.FileDescriptorResponse, error) {
+	response := &rpb.FileDescriptorResponse{}
+	var add func(fd *desc.FileDescriptor, always bool) error
+	add = func(fd *desc.FileDescriptor, always bool) error {
+		if _, ok := s.sent[fd.GetName()]; ok && !always {
+			return nil
+		}
+		s.sent[fd.GetName()] = struct{}{}
+		if filtered, ok := s.filtered[fd.GetName()]; ok {
+			fd = filtered
+		}
+		data, err := proto.Marshal(fd.AsFileDescriptorProto())
+		if err != nil {
+			return err
+		}
+		response.FileDescriptorProto = append(response.FileDescriptorProto, data)
+		for _, dep := range fd.GetDependencies() {
+			if err := add(dep, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return response, add(fd, true)
+}
+
+// errorResponse converts the error into an ErrorResponse with its status code
+func errorResponse(err error) *rpb.ServerReflectionResponse_ErrorResponse {
+	code := codes.Unknown
+	if grpcreflect.IsElementNotFoundError(err) {
+		code = codes.NotFound
+	} else if s, ok := status.FromError(err); ok {
+		code = s.Code()
+	}
+	return &rpb.ServerReflectionResponse_ErrorResponse{
+		ErrorResponse: &rpb.ErrorResponse{
+			ErrorCode:    int32(code),
+			ErrorMessage: err.Error(),
+		},
+	}
+}
@@ -0,0 +1,358 @@
+package grpcfilter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wdullaer/proto-filter/protofilter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var reflectionTestFiles = map[string]string{
+	"api.proto": `
+syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+message Request {
+    string name = 1;
+    string secret = 2 [(filter.field).include = "internal"];
+    Detail detail = 3;
+}
+
+message Detail {
+    option (filter.message).include = "internal";
+    string key = 1;
+}
+
+service Public {
+    rpc Get(Request) returns (Request);
+    rpc Hidden(Request) returns (Request) {
+        option (filter.method).include = "internal";
+    }
+}
+
+service Admin {
+    option (filter.service).include = "internal";
+    rpc Reset(Request) returns (Request);
+}
+`,
+	"ext.proto": `
+syntax = "proto2";
+package test;
+
+import "filter/filter.proto";
+
+message Base {
+    extensions 100 to 200;
+}
+
+extend Base {
+    optional string visible = 100;
+    optional string hidden = 101 [(filter.field).include = "internal"];
+}
+`,
+}
+
+// parseTestFiles is a test helper which parses the files in
+// reflectionTestFiles, with filter/filter.proto read from the repository
+func parseTestFiles(t *testing.T, names ...string) []*desc.FileDescriptor {
+	parser := protoparse.Parser{
+		Accessor: func(filename string) (io.ReadCloser, error) {
+			if contents, ok := reflectionTestFiles[filename]; ok {
+				return ioutil.NopCloser(strings.NewReader(contents)), nil
+			}
+			return os.Open(filepath.Join("..", filename))
+		},
+	}
+	descs, err := parser.ParseFiles(names...)
+	require.NoError(t, err)
+	return descs
+}
+
+// testUpstream is an in-process reflection service which answers from parsed
+// files, like the reflection service of a real server
+type testUpstream struct {
+	files    map[string]*desc.FileDescriptor
+	services []string
+}
+
+func newTestUpstream(descs []*desc.FileDescriptor) *testUpstream {
+	upstream := &testUpstream{files: make(map[string]*desc.FileDescriptor)}
+	var add func(fd *desc.FileDescriptor)
+	add = func(fd *desc.FileDescriptor) {
+		upstream.files[fd.GetName()] = fd
+		for _, dep := range fd.GetDependencies() {
+			add(dep)
+		}
+	}
+	for _, fd := range descs {
+		add(fd)
+		for _, service := range fd.GetServices() {
+			upstream.services = append(upstream.services, service.GetFullyQualifiedName())
+		}
+	}
+	return upstream
+}
+
+func (u *testUpstream) ServerReflectionInfo(stream rpb.ServerReflection_ServerReflectionInfoServer) error {
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		response, err := u.respond(request)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+	}
+}
+
+// respond answers a single reflection request from the files of the upstream
+func (u *testUpstream) respond(request *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+	response := &rpb.ServerReflectionResponse{OriginalRequest: request}
+	var found *desc.FileDescriptor
+	switch r := request.GetMessageRequest().(type) {
+	case *rpb.ServerReflectionRequest_FileByFilename:
+		found = u.files[r.FileByFilename]
+	case *rpb.ServerReflectionRequest_FileContainingSymbol:
+		for _, fd := range u.files {
+			if fd.FindSymbol(r.FileContainingSymbol) != nil {
+				found = fd
+			}
+		}
+	case *rpb.ServerReflectionRequest_FileContainingExtension:
+		found = u.findExtension(r.FileContainingExtension.GetContainingType(), r.FileContainingExtension.GetExtensionNumber())
+	case *rpb.ServerReflectionRequest_AllExtensionNumbersOfType:
+		numbers := &rpb.ExtensionNumberResponse{BaseTypeName: r.AllExtensionNumbersOfType}
+		for _, fd := range u.files {
+			for _, extension := range fd.GetExtensions() {
+				if extension.GetOwner().GetFullyQualifiedName() == r.AllExtensionNumbersOfType {
+					numbers.ExtensionNumber = append(numbers.ExtensionNumber, extension.GetNumber())
+				}
+			}
+		}
+		response.MessageResponse = &rpb.ServerReflectionResponse_AllExtensionNumbersResponse{AllExtensionNumbersResponse: numbers}
+	case *rpb.ServerReflectionRequest_ListServices:
+		services := &rpb.ListServiceResponse{}
+		for _, name := range u.services {
+			services.Service = append(services.Service, &rpb.ServiceResponse{Name: name})
+		}
+		response.MessageResponse = &rpb.ServerReflectionResponse_ListServicesResponse{ListServicesResponse: services}
+	}
+	if found != nil {
+		files, err := fileResponse(found)
+		if err != nil {
+			return nil, err
+		}
+		response.MessageResponse = &rpb.ServerReflectionResponse_FileDescriptorResponse{FileDescriptorResponse: files}
+	} else if response.MessageResponse == nil {
+		response.MessageResponse = &rpb.ServerReflectionResponse_ErrorResponse{
+			ErrorResponse: &rpb.ErrorResponse{ErrorCode: int32(codes.NotFound), ErrorMessage: "not found"},
+		}
+	}
+	return response, nil
+}
+
+// findExtension returns the file that defines the extension of the type with
+// the number, or nil
+func (u *testUpstream) findExtension(extendee string, number int32) *desc.FileDescriptor {
+	for _, fd := range u.files {
+		for _, extension := range fd.GetExtensions() {
+			if extension.GetOwner().GetFullyQualifiedName() == extendee && extension.GetNumber() == number {
+				return fd
+			}
+		}
+	}
+	return nil
+}
+
+// fileResponse returns the file and its dependencies, serialized
+func fileResponse(fd *desc.FileDescriptor) (*rpb.FileDescriptorResponse, error) {
+	files := &rpb.FileDescriptorResponse{}
+	for _, file := range append([]*desc.FileDescriptor{fd}, fd.GetDependencies()...) {
+		data, err := proto.Marshal(file.AsFileDescriptorProto())
+		if err != nil {
+			return nil, err
+		}
+		files.FileDescriptorProto = append(files.FileDescriptorProto, data)
+	}
+	return files, nil
+}
+
+// serve is a test helper which serves register on an in-memory listener. It
+// returns a connection to the server and a function to stop it again.
+func serve(t *testing.T, register func(*grpc.Server)) (*grpc.ClientConn, func()) {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	register(server)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	conn, err := grpc.Dial("bufconn", grpc.WithInsecure(), grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	require.NoError(t, err)
+	return conn, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+// startProxy is a test helper which starts a proxy in front of an upstream
+// serving the test files. It returns a reflection client for the given terms
+// and a function to stop the servers again.
+func startProxy(t *testing.T, terms TermsFunc, options protofilter.Options, clientTerms string) (*grpcreflect.Client, func()) {
+	upstream := newTestUpstream(parseTestFiles(t, "api.proto", "ext.proto"))
+	upstreamConn, stopUpstream := serve(t, func(server *grpc.Server) {
+		rpb.RegisterServerReflectionServer(server, upstream)
+	})
+	proxy := NewReflectionProxy(rpb.NewServerReflectionClient(upstreamConn), terms, options)
+	proxyConn, stopProxy := serve(t, proxy.Register)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if len(clientTerms) != 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs("x-proto-filter-terms", clientTerms))
+	}
+	client := grpcreflect.NewClient(ctx, rpb.NewServerReflectionClient(proxyConn))
+	return client, func() {
+		client.Reset()
+		cancel()
+		stopProxy()
+		stopUpstream()
+	}
+}
+
+func TestReflectionProxy(t *testing.T) {
+	terms := TermsFromMetadata("x-proto-filter-terms", nil)
+
+	t.Run("Should only list the services visible to the client", func(t *testing.T) {
+		for clientTerms, expected := range map[string][]string{
+			"partner":  {"test.Public"},
+			"internal": {"test.Admin", "test.Public"},
+		} {
+			client, stop := startProxy(t, terms, protofilter.Options{}, clientTerms)
+			services, err := client.ListServices()
+			stop()
+			if assert.NoError(t, err, clientTerms) {
+				sort.Strings(services)
+				assert.Equal(t, expected, services, clientTerms)
+			}
+		}
+	})
+
+	t.Run("Should remove hidden methods and fields from the files", func(t *testing.T) {
+		client, stop := startProxy(t, terms, protofilter.Options{}, "partner")
+		defer stop()
+
+		service, err := client.ResolveService("test.Public")
+		if assert.NoError(t, err) {
+			assert.NotNil(t, service.FindMethodByName("Get"))
+			assert.Nil(t, service.FindMethodByName("Hidden"))
+		}
+		message, err := client.ResolveMessage("test.Request")
+		if assert.NoError(t, err) {
+			assert.NotNil(t, message.FindFieldByName("name"))
+			assert.Nil(t, message.FindFieldByName("secret"))
+		}
+	})
+
+	t.Run("Should remove the fields that refer to a hidden message", func(t *testing.T) {
+		client, stop := startProxy(t, terms, protofilter.Options{Dangling: protofilter.DanglingFail}, "partner")
+		defer stop()
+
+		message, err := client.ResolveMessage("test.Request")
+		if assert.NoError(t, err) {
+			assert.NotNil(t, message.FindFieldByName("name"))
+			assert.Nil(t, message.FindFieldByName("detail"))
+		}
+		_, err = client.FileContainingSymbol("test.Detail")
+		assert.True(t, grpcreflect.IsElementNotFoundError(err))
+	})
+
+	t.Run("Should not return the details of a filter error to the client", func(t *testing.T) {
+		failing := protofilter.RuleFunc(func(element protofilter.Element) (protofilter.Decision, error) {
+			if element.Name == "test.Detail" {
+				return protofilter.Abstain, errors.New("Failed")
+			}
+			return protofilter.Abstain, nil
+		})
+		client, stop := startProxy(t, terms, protofilter.Options{Rules: []protofilter.Rule{failing}}, "partner")
+		defer stop()
+
+		_, err := client.ListServices()
+		if s, ok := status.FromError(err); assert.True(t, ok) {
+			assert.Equal(t, codes.Internal, s.Code())
+			assert.NotContains(t, s.Message(), "test.Detail")
+		}
+	})
+
+	t.Run("Should not find hidden symbols", func(t *testing.T) {
+		client, stop := startProxy(t, terms, protofilter.Options{}, "partner")
+		defer stop()
+
+		for _, symbol := range []string{"test.Admin", "test.Public.Hidden", "test.Detail"} {
+			_, err := client.FileContainingSymbol(symbol)
+			assert.True(t, grpcreflect.IsElementNotFoundError(err), symbol)
+		}
+	})
+
+	t.Run("Should hide the extensions that are removed", func(t *testing.T) {
+		client, stop := startProxy(t, terms, protofilter.Options{}, "partner")
+		defer stop()
+
+		numbers, err := client.AllExtensionNumbersForType("test.Base")
+		if assert.NoError(t, err) {
+			assert.Equal(t, []int32{100}, numbers)
+		}
+		_, err = client.FileContainingExtension("test.Base", 101)
+		assert.True(t, grpcreflect.IsElementNotFoundError(err))
+	})
+
+	t.Run("Should apply the filter options", func(t *testing.T) {
+		client, stop := startProxy(t, terms, protofilter.Options{Policy: protofilter.PolicyDeny}, "partner")
+		defer stop()
+
+		services, err := client.ListServices()
+		if assert.NoError(t, err) {
+			assert.Empty(t, services)
+		}
+	})
+
+	t.Run("Should reject clients without terms", func(t *testing.T) {
+		client, stop := startProxy(t, terms, protofilter.Options{}, "")
+		defer stop()
+
+		_, err := client.ListServices()
+		if s, ok := status.FromError(err); assert.True(t, ok) {
+			assert.Equal(t, codes.InvalidArgument, s.Code())
+		}
+	})
+}
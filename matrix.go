@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/wdullaer/proto-filter/protofilter"
+)
+
+// matrixWriter returns the writer of the visibility matrix in the format with
+// the given name, or false if there is no such format
+func matrixWriter(format string) (func(w io.Writer, matrix *protofilter.VisibilityMatrix) error, bool) {
+	switch format {
+	case "csv":
+		return writeMatrixCSV, true
+	case "markdown":
+		return writeMatrixMarkdown, true
+	case "html":
+		return writeMatrixHTML, true
+	}
+	return nil, false
+}
+
+// writeMatrixCSV writes the matrix with a row per element and a column per
+// term, which contains yes if the element is visible for the term
+func writeMatrixCSV(w io.Writer, matrix *protofilter.VisibilityMatrix) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append([]string{"kind", "name", "file"}, matrix.Terms...)); err != nil {
+		return err
+	}
+	for _, row := range matrix.Rows {
+		record := []string{row.Kind.String(), row.Name, row.File}
+		for _, visible := range row.Visible {
+			if visible {
+				record = append(record, "yes")
+			} else {
+				record = append(record, "no")
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeMatrixMarkdown writes the matrix as a Markdown table
+func writeMatrixMarkdown(w io.Writer, matrix *protofilter.VisibilityMatrix) error {
+	var b strings.Builder
+	b.WriteString("| Element | Kind |")
+	for _, term := range matrix.Terms {
+		fmt.Fprintf(&b, " %s |", escapeMarkdown(term))
+	}
+	b.WriteString("\n| --- | --- |")
+	for range matrix.Terms {
+		b.WriteString(" :-: |")
+	}
+	b.WriteString("\n")
+	for _, row := range matrix.Rows {
+		fmt.Fprintf(&b, "| `%s` | %s |", row.Name, row.Kind)
+		for _, visible := range row.Visible {
+			if visible {
+				b.WriteString(" ✓ |")
+			} else {
+				b.WriteString(" ✗ |")
+			}
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer("|", `\|`, "*", `\*`, "_", `\_`).Replace(s)
+}
+
+// matrixNode is an element in the HTML version of the matrix, with the
+// elements it encloses
+type matrixNode struct {
+	Row      protofilter.MatrixRow
+	Label    string
+	Children []*matrixNode
+}
+
+type matrixPackage struct {
+	Name  string
+	Nodes []*matrixNode
+}
+
+// matrixTree groups the rows by package and nests them in their enclosing
+// elements
+func matrixTree(matrix *protofilter.VisibilityMatrix) []*matrixPackage {
+	var packages []*matrixPackage
+	byName := make(map[string]*matrixPackage)
+	var stack []*matrixNode
+	for _, row := range matrix.Rows {
+		node := &matrixNode{Row: row, Label: row.Name[strings.LastIndex(row.Name, ".")+1:]}
+		if row.Depth == 0 {
+			pkg, ok := byName[row.Package]
+			if !ok {
+				pkg = &matrixPackage{Name: row.Package}
+				byName[row.Package] = pkg
+				packages = append(packages, pkg)
+			}
+			pkg.Nodes = append(pkg.Nodes, node)
+			stack = stack[:0]
+		} else {
+			stack = stack[:row.Depth]
+			parent := stack[row.Depth-1]
+			parent.Children = append(parent.Children, node)
+		}
+		stack = append(stack, node)
+	}
+	return packages
+}
+
+// matrixTemplate is the HTML page writeMatrixHTML fills in
+const matrixTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Visibility matrix</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+.row { display: flex; align-items: center; border-bottom: 1px solid #eee; }
+.name { flex: 1; padding: 2px 4px; }
+.kind { color: #888; margin-right: 4px; }
+.cell { width: 6em; text-align: center; }
+.visible { color: #1a7f37; }
+.hidden { color: #cf222e; }
+.header { font-weight: bold; border-bottom: 2px solid #ccc; }
+.package > summary { font-weight: bold; background: #f6f8fa; }
+summary { cursor: pointer; }
+summary.row { display: flex; list-style: none; }
+summary.row::before { content: "▸"; width: 1em; }
+details[open] > summary.row::before { content: "▾"; }
+.children { margin-left: 1.5em; }
+.leaf { padding-left: 1em; }
+</style>
+</head>
+<body>
+<h1>Visibility matrix</h1>
+<div class="row header"><span class="name">Element</span>{{range .Terms}}<span class="cell">{{.}}</span>{{end}}</div>
+{{range .Packages}}<details class="package" open>
+<summary class="row"><span class="name">{{if .Name}}package {{.Name}}{{else}}(no package){{end}}</span></summary>
+<div class="children">
+{{range .Nodes}}{{template "node" .}}{{end}}</div>
+</details>
+{{end}}</body>
+</html>
+{{define "cells"}}{{range .}}{{if .}}<span class="cell visible">✓</span>{{else}}<span class="cell hidden">✗</span>{{end}}{{end}}{{end}}
+{{define "node"}}{{if .Children}}<details>
+<summary class="row" title="{{.Row.Name}}"><span class="name"><span class="kind">{{.Row.Kind}}</span>{{.Label}}</span>{{template "cells" .Row.Visible}}</summary>
+<div class="children">
+{{range .Children}}{{template "node" .}}{{end}}</div>
+</details>
+{{else}}<div class="row leaf" title="{{.Row.Name}}"><span class="name"><span class="kind">{{.Row.Kind}}</span>{{.Label}}</span>{{template "cells" .Row.Visible}}</div>
+{{end}}{{end}}`
+
+// writeMatrixHTML writes the matrix as a self-contained HTML page, in which
+// packages, messages and services can be collapsed
+func writeMatrixHTML(w io.Writer, matrix *protofilter.VisibilityMatrix) error {
+	page, err := template.New("matrix").Parse(matrixTemplate)
+	if err != nil {
+		return err
+	}
+	return page.Execute(w, struct {
+		Terms    []string
+		Packages []*matrixPackage
+	}{matrix.Terms, matrixTree(matrix)})
+}
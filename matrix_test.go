@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wdullaer/proto-filter/protofilter"
+)
+
+// testMatrix is a small matrix with a nested message and two packages
+var testMatrix = &protofilter.VisibilityMatrix{
+	Terms: []string{"JP", "NA"},
+	Rows: []protofilter.MatrixRow{
+		{Kind: protofilter.KindMessage, Name: "a.Foo", File: "a.proto", Package: "a", Depth: 0, Visible: []bool{true, true}},
+		{Kind: protofilter.KindField, Name: "a.Foo.bar", File: "a.proto", Package: "a", Depth: 1, Visible: []bool{false, true}},
+		{Kind: protofilter.KindMessage, Name: "a.Foo.Nested", File: "a.proto", Package: "a", Depth: 1, Visible: []bool{true, false}},
+		{Kind: protofilter.KindField, Name: "a.Foo.Nested.baz", File: "a.proto", Package: "a", Depth: 2, Visible: []bool{true, false}},
+		{Kind: protofilter.KindService, Name: "b.Service", File: "b.proto", Package: "b", Depth: 0, Visible: []bool{true, true}},
+	},
+}
+
+func TestWriteMatrixCSV(t *testing.T) {
+	t.Run("Should write a row per element and a column per term", func(t *testing.T) {
+		var buf bytes.Buffer
+		if assert.NoError(t, writeMatrixCSV(&buf, testMatrix)) {
+			assert.Equal(t, `kind,name,file,JP,NA
+message,a.Foo,a.proto,yes,yes
+field,a.Foo.bar,a.proto,no,yes
+message,a.Foo.Nested,a.proto,yes,no
+field,a.Foo.Nested.baz,a.proto,yes,no
+service,b.Service,b.proto,yes,yes
+`, buf.String())
+		}
+	})
+}
+
+func TestWriteMatrixMarkdown(t *testing.T) {
+	t.Run("Should write a table", func(t *testing.T) {
+		var buf bytes.Buffer
+		if assert.NoError(t, writeMatrixMarkdown(&buf, testMatrix)) {
+			assert.Equal(t, "| Element | Kind | JP | NA |\n"+
+				"| --- | --- | :-: | :-: |\n"+
+				"| `a.Foo` | message | ✓ | ✓ |\n"+
+				"| `a.Foo.bar` | field | ✗ | ✓ |\n"+
+				"| `a.Foo.Nested` | message | ✓ | ✗ |\n"+
+				"| `a.Foo.Nested.baz` | field | ✓ | ✗ |\n"+
+				"| `b.Service` | service | ✓ | ✓ |\n", buf.String())
+		}
+	})
+}
+
+func TestMatrixTree(t *testing.T) {
+	t.Run("Should group the rows by package and nest them in their parents", func(t *testing.T) {
+		packages := matrixTree(testMatrix)
+
+		if assert.Len(t, packages, 2) {
+			assert.Equal(t, "a", packages[0].Name)
+			if assert.Len(t, packages[0].Nodes, 1) {
+				foo := packages[0].Nodes[0]
+				assert.Equal(t, "Foo", foo.Label)
+				if assert.Len(t, foo.Children, 2) {
+					assert.Equal(t, "bar", foo.Children[0].Label)
+					assert.Equal(t, "Nested", foo.Children[1].Label)
+					assert.Len(t, foo.Children[1].Children, 1)
+				}
+			}
+			assert.Equal(t, "b", packages[1].Name)
+			assert.Len(t, packages[1].Nodes, 1)
+		}
+	})
+}
+
+func TestWriteMatrixHTML(t *testing.T) {
+	t.Run("Should write collapsible packages and elements", func(t *testing.T) {
+		var buf bytes.Buffer
+		if assert.NoError(t, writeMatrixHTML(&buf, testMatrix)) {
+			html := buf.String()
+			assert.Contains(t, html, `<span class="name">package a</span>`)
+			assert.Contains(t, html, `<summary class="row" title="a.Foo"><span class="name"><span class="kind">message</span>Foo</span><span class="cell visible">✓</span><span class="cell visible">✓</span></summary>`)
+			assert.Contains(t, html, `<div class="row leaf" title="a.Foo.bar"><span class="name"><span class="kind">field</span>bar</span><span class="cell hidden">✗</span><span class="cell visible">✓</span></div>`)
+			assert.NotContains(t, html, "<script", "Should not need scripts")
+		}
+	})
+}
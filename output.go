@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoprint"
+	"github.com/wdullaer/proto-filter/protofilter"
+)
+
+// The formats the filtered files can be written in
+const (
+	formatProto         = "proto"
+	formatDescriptorSet = "descriptor-set"
+	formatJSON          = "json"
+)
+
+// outputFormats returns the valid values of Config.Format
+func outputFormats() []string {
+	return []string{formatProto, formatDescriptorSet, formatJSON}
+}
+
+// descriptorSetFile returns the name of the file a descriptor set is written to
+// in the output directory in the given format
+func descriptorSetFile(format string) string {
+	if format == formatJSON {
+		return "filtered.json"
+	}
+	return "filtered.pb"
+}
+
+// writeOutput writes the files to the output directory in the configured
+// format. The same files always produce the same bytes, so the output can be
+// checked in.
+func writeOutput(config *Config, printer *protoprint.Printer, files []*desc.FileDescriptor, output string) error {
+	if config.Format == formatProto || len(config.Format) == 0 {
+		return printer.PrintProtosToFileSystem(files, output)
+	}
+
+	set := protofilter.DescriptorSet(files, protofilter.DescriptorSetOptions{
+		IncludeImports:    config.IncludeImports,
+		IncludeSourceInfo: config.IncludeSourceInfo,
+	})
+	data, err := marshalDescriptorSet(set, config.Format)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(output, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(output, descriptorSetFile(config.Format)), data, 0644)
+}
+
+// marshalDescriptorSet encodes the set deterministically in the given format
+func marshalDescriptorSet(set proto.Message, format string) ([]byte, error) {
+	switch format {
+	case formatDescriptorSet:
+		buffer := proto.NewBuffer(nil)
+		buffer.SetDeterministic(true)
+		if err := buffer.Marshal(set); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	case formatJSON:
+		var buffer bytes.Buffer
+		marshaler := jsonpb.Marshaler{Indent: "  "}
+		if err := marshaler.Marshal(&buffer, set); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte('\n')
+		return buffer.Bytes(), nil
+	}
+	return nil, fmt.Errorf("Unknown format %q", format)
+}
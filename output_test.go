@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc/protoprint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wdullaer/proto-filter/protofilter"
+)
+
+func TestWriteOutput(t *testing.T) {
+	descs, err := protofilter.Parse([]string{"example/test.proto"}, []string{"."})
+	require.NoError(t, err)
+	result, err := protofilter.Filter(descs, protofilter.Options{Terms: []string{"NA"}})
+	require.NoError(t, err)
+	printer := &protoprint.Printer{}
+
+	t.Run("Should write proto files by default", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+
+		require.NoError(t, writeOutput(&Config{}, printer, result.Files, dir))
+		assert.FileExists(t, filepath.Join(dir, "example", "test.proto"))
+	})
+
+	t.Run("Should write a binary descriptor set without imports", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+
+		require.NoError(t, writeOutput(&Config{Format: formatDescriptorSet}, printer, result.Files, dir))
+		data, err := ioutil.ReadFile(filepath.Join(dir, "filtered.pb"))
+		require.NoError(t, err)
+		set := &dpb.FileDescriptorSet{}
+		require.NoError(t, proto.Unmarshal(data, set))
+		if assert.Len(t, set.GetFile(), 1) {
+			assert.Equal(t, "example/test.proto", set.GetFile()[0].GetName())
+			assert.Nil(t, set.GetFile()[0].GetSourceCodeInfo())
+		}
+	})
+
+	t.Run("Should write a JSON descriptor set with imports", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+
+		config := &Config{Format: formatJSON, IncludeImports: true}
+		require.NoError(t, writeOutput(config, printer, result.Files, dir))
+		data, err := ioutil.ReadFile(filepath.Join(dir, "filtered.json"))
+		require.NoError(t, err)
+		set := &dpb.FileDescriptorSet{}
+		require.NoError(t, jsonpb.UnmarshalString(string(data), set))
+		names := make([]string, len(set.GetFile()))
+		for i, file := range set.GetFile() {
+			names[i] = file.GetName()
+		}
+		assert.Contains(t, names, "filter/filter.proto")
+		assert.Equal(t, "example/test.proto", names[len(names)-1])
+	})
+
+	t.Run("Should write the same bytes every time", func(t *testing.T) {
+		for _, format := range []string{formatDescriptorSet, formatJSON} {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+			config := &Config{Format: format, IncludeImports: true, IncludeSourceInfo: true}
+			path := filepath.Join(dir, descriptorSetFile(format))
+
+			require.NoError(t, writeOutput(config, printer, result.Files, dir))
+			first, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			require.NoError(t, writeOutput(config, printer, result.Files, dir))
+			second, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, first, second, format)
+		}
+	})
+}
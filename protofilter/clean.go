@@ -0,0 +1,55 @@
+package protofilter
+
+import (
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc/builder"
+	"github.com/wdullaer/proto-filter/filter"
+)
+
+// cleanAnnotations recursively removes the filter annotations from the options
+// of the builder and all of its children, so the output no longer reveals
+// which terms exist. Once nothing uses filter.proto anymore, pruneImports will
+// drop the import as well.
+//
+// The options are copied before they are modified, since builders created with
+// builder.FromFile share them with the original descriptors.
+func cleanAnnotations(b builder.Builder) {
+	switch b := b.(type) {
+	case *builder.FileBuilder:
+		// The declared terms name the audiences as well
+		options := withoutAnnotation(b.Options, filter.E_File)
+		b.Options = withoutAnnotation(options, filter.E_Terms).(*dpb.FileOptions)
+	case *builder.MessageBuilder:
+		b.Options = withoutAnnotation(b.Options, filter.E_Message).(*dpb.MessageOptions)
+	case *builder.FieldBuilder:
+		b.Options = withoutAnnotation(b.Options, filter.E_Field).(*dpb.FieldOptions)
+	case *builder.OneOfBuilder:
+		b.Options = withoutAnnotation(b.Options, filter.E_OneOf).(*dpb.OneofOptions)
+	case *builder.EnumBuilder:
+		b.Options = withoutAnnotation(b.Options, filter.E_Enum).(*dpb.EnumOptions)
+	case *builder.EnumValueBuilder:
+		b.Options = withoutAnnotation(b.Options, filter.E_EnumValue).(*dpb.EnumValueOptions)
+	case *builder.ServiceBuilder:
+		b.Options = withoutAnnotation(b.Options, filter.E_Service).(*dpb.ServiceOptions)
+	case *builder.MethodBuilder:
+		b.Options = withoutAnnotation(b.Options, filter.E_Method).(*dpb.MethodOptions)
+	}
+
+	for _, child := range b.GetChildren() {
+		cleanAnnotations(child)
+	}
+}
+
+// withoutAnnotation returns a copy of the options without the extension, or
+// the options themselves if they are nil or the extension is not set
+func withoutAnnotation(options proto.Message, ext *proto.ExtensionDesc) proto.Message {
+	if reflect.ValueOf(options).IsNil() || !proto.HasExtension(options, ext) {
+		return options
+	}
+	clone := proto.Clone(options)
+	proto.ClearExtension(clone, ext)
+	return clone
+}
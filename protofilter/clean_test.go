@@ -0,0 +1,114 @@
+package protofilter
+
+import (
+	"testing"
+
+	"github.com/Workiva/go-datastructures/set"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wdullaer/proto-filter/filter"
+)
+
+const cleanTestProto = `
+syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+option (filter.file).include = "foo";
+option (filter.terms).term = { name: "foo" description: "Foo" };
+
+message Message {
+    option (filter.message).include = "foo";
+    string field = 1 [deprecated = true, (filter.field).include = "foo"];
+    oneof choice {
+        option (filter.one_of).include = "foo";
+        string first = 2;
+    }
+}
+
+enum Enum {
+    option (filter.enum).include = "foo";
+    ENUM_DEFAULT = 0 [(filter.enum_value).include = "foo"];
+}
+
+service Service {
+    option (filter.service).include = "foo";
+    rpc Method(Message) returns (Message) {
+        option (filter.method).include = "foo";
+    }
+}
+`
+
+func TestCleanAnnotations(t *testing.T) {
+	files := map[string]string{"clean.proto": cleanTestProto}
+
+	t.Run("Should remove all filter annotations", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "clean.proto")
+		filtered := filterTestFiles(t, descs, set.New("foo"))
+		cleanAnnotations(filtered["clean.proto"])
+
+		fd, err := filtered["clean.proto"].Build()
+		require.NoError(t, err)
+		message := fd.FindMessage("test.Message")
+		enum := fd.FindEnum("test.Enum")
+		service := fd.FindService("test.Service")
+		for _, options := range []proto.Message{
+			fd.GetFileOptions(),
+			message.GetMessageOptions(),
+			message.FindFieldByName("field").GetFieldOptions(),
+			message.GetOneOfs()[0].GetOneOfOptions(),
+			enum.GetEnumOptions(),
+			enum.GetValues()[0].GetEnumValueOptions(),
+			service.GetServiceOptions(),
+			service.FindMethodByName("Method").GetMethodOptions(),
+		} {
+			assert.NotContains(t, proto.CompactTextString(options), "filter")
+		}
+	})
+
+	t.Run("Should keep other options", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "clean.proto")
+		filtered := filterTestFiles(t, descs, set.New("foo"))
+		cleanAnnotations(filtered["clean.proto"])
+
+		fd, err := filtered["clean.proto"].Build()
+		require.NoError(t, err)
+		assert.True(t, fd.FindMessage("test.Message").FindFieldByName("field").GetFieldOptions().GetDeprecated())
+	})
+
+	t.Run("Should not modify the original descriptors", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "clean.proto")
+		filtered := filterTestFiles(t, descs, set.New("foo"))
+		cleanAnnotations(filtered["clean.proto"])
+
+		options := descs[0].FindMessage("test.Message").FindFieldByName("field").GetFieldOptions()
+		assert.True(t, proto.HasExtension(options, filter.E_Field))
+	})
+
+	t.Run("Should drop the filter.proto import once it is unused", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "clean.proto")
+		filtered := filterTestFiles(t, descs, set.New("foo"))
+		cleanAnnotations(filtered["clean.proto"])
+
+		fd, err := filtered["clean.proto"].Build()
+		require.NoError(t, err)
+		fd, err = pruneImports(fd, descs[0], nil)
+		require.NoError(t, err)
+		assert.Empty(t, fd.AsFileDescriptorProto().GetDependency())
+	})
+
+	t.Run("Should remove the declared terms", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "clean.proto")
+		filtered := filterTestFiles(t, descs, set.New("foo"))
+		cleanAnnotations(filtered["clean.proto"])
+
+		fd, err := filtered["clean.proto"].Build()
+		require.NoError(t, err)
+		assert.False(t, proto.HasExtension(fd.GetFileOptions(), filter.E_Terms))
+		fd, err = pruneImports(fd, descs[0], nil)
+		require.NoError(t, err)
+		assert.NotContains(t, fd.AsFileDescriptorProto().GetDependency(), "filter/filter.proto")
+	})
+}
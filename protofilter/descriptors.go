@@ -0,0 +1,139 @@
+package protofilter
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+)
+
+// LoadDescriptorSets reads the FileDescriptorSets at the given paths, as
+// written by `protoc -o` or `buf build -o`, and returns the descriptors of the
+// named files. If no names are given, all files are returned except
+// filter/filter.proto and the files of google/protobuf, which sets often
+// include as imports.
+//
+// A set can be in the binary or the JSON format. If it contains source info,
+// it is kept, and with it the comments.
+func LoadDescriptorSets(paths []string, names []string) ([]*desc.FileDescriptor, error) {
+	var protos []*dpb.FileDescriptorProto
+	seen := make(map[string]struct{})
+	for _, path := range paths {
+		set, err := readDescriptorSet(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for _, fdProto := range set.GetFile() {
+			if _, ok := seen[fdProto.GetName()]; ok {
+				continue
+			}
+			seen[fdProto.GetName()] = struct{}{}
+			protos = append(protos, fdProto)
+		}
+	}
+	if len(names) == 0 {
+		for _, fdProto := range protos {
+			if !IsLibraryFile(fdProto.GetName()) {
+				names = append(names, fdProto.GetName())
+			}
+		}
+	}
+	return FromFileDescriptorProtos(protos, names)
+}
+
+// IsLibraryFile reports whether the file is filter/filter.proto or one of the
+// files of google/protobuf. They are imported by the files that are filtered,
+// but never filtered themselves.
+func IsLibraryFile(name string) bool {
+	return name == "filter/filter.proto" || strings.HasPrefix(name, "google/protobuf/")
+}
+
+// readDescriptorSet reads a binary or JSON FileDescriptorSet
+func readDescriptorSet(path string) (*dpb.FileDescriptorSet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := &dpb.FileDescriptorSet{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) != 0 && trimmed[0] == '{' {
+		err = jsonpb.Unmarshal(bytes.NewReader(data), set)
+	} else {
+		err = proto.Unmarshal(data, set)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid FileDescriptorSet: %v", err)
+	}
+	return set, nil
+}
+
+// FromFileDescriptorProtos creates the descriptors of the named files from
+// already compiled descriptor protos, as found in a FileDescriptorSet or a
+// protoc CodeGeneratorRequest. The protos have to include every file the named
+// files import.
+func FromFileDescriptorProtos(protos []*dpb.FileDescriptorProto, names []string) ([]*desc.FileDescriptor, error) {
+	all, err := desc.CreateFileDescriptors(protos)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*desc.FileDescriptor, len(names))
+	for i, name := range names {
+		fd, ok := all[name]
+		if !ok {
+			return nil, fmt.Errorf("File %q is not part of the descriptors", name)
+		}
+		result[i] = fd
+	}
+	return result, nil
+}
+
+// DescriptorSetOptions determine what DescriptorSet includes
+type DescriptorSetOptions struct {
+	// IncludeImports adds all files the files import, like protoc's
+	// --include_imports
+	IncludeImports bool
+	// IncludeSourceInfo keeps the source info, and with it the comments
+	IncludeSourceInfo bool
+}
+
+// DescriptorSet returns a FileDescriptorSet holding the files, in topological
+// order. An import of one of the files refers to the version in files, so
+// filtered files can be combined.
+func DescriptorSet(files []*desc.FileDescriptor, options DescriptorSetOptions) *dpb.FileDescriptorSet {
+	byName := make(map[string]*desc.FileDescriptor, len(files))
+	for _, fd := range files {
+		byName[fd.GetName()] = fd
+	}
+	set := &dpb.FileDescriptorSet{}
+	seen := make(map[string]struct{})
+	var add func(fd *desc.FileDescriptor)
+	add = func(fd *desc.FileDescriptor) {
+		replacement, isFile := byName[fd.GetName()]
+		if isFile {
+			fd = replacement
+		}
+		if _, ok := seen[fd.GetName()]; ok {
+			return
+		}
+		seen[fd.GetName()] = struct{}{}
+		for _, dep := range fd.GetDependencies() {
+			add(dep)
+		}
+		if !isFile && !options.IncludeImports {
+			return
+		}
+		fdProto := proto.Clone(fd.AsFileDescriptorProto()).(*dpb.FileDescriptorProto)
+		if !options.IncludeSourceInfo {
+			fdProto.SourceCodeInfo = nil
+		}
+		set.File = append(set.File, fdProto)
+	}
+	for _, fd := range files {
+		add(fd)
+	}
+	return set
+}
@@ -0,0 +1,155 @@
+package protofilter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescriptorSet(t *testing.T) {
+	files := map[string]string{
+		"base.proto": `syntax = "proto3";
+package test;
+import "filter/filter.proto";
+message Base {
+    string name = 1;
+    string secret = 2 [(filter.field).exclude = "foo"];
+}
+`,
+		"user.proto": `syntax = "proto3";
+package test;
+import "base.proto";
+message User {
+    Base base = 1;
+}
+`,
+	}
+
+	t.Run("Should add the imports in topological order and use the filtered files", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "user.proto", "base.proto")
+		result, err := Filter(descs, Options{Terms: []string{"foo"}})
+		require.NoError(t, err)
+
+		set := DescriptorSet(result.Files, DescriptorSetOptions{IncludeImports: true})
+
+		names := make([]string, len(set.GetFile()))
+		for i, file := range set.GetFile() {
+			names[i] = file.GetName()
+			assert.Nil(t, file.GetSourceCodeInfo())
+		}
+		// The import of filter.proto is pruned together with the last annotation
+		assert.Equal(t, []string{"base.proto", "user.proto"}, names)
+
+		roundTrip, err := FromFileDescriptorProtos(set.GetFile(), []string{"base.proto", "user.proto"})
+		if assert.NoError(t, err) {
+			assert.Nil(t, roundTrip[0].FindMessage("test.Base").FindFieldByName("secret"))
+			assert.NotNil(t, roundTrip[1].FindMessage("test.User"))
+		}
+	})
+
+	t.Run("Should add the imports of the files", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "user.proto")
+
+		set := DescriptorSet(descs, DescriptorSetOptions{IncludeImports: true})
+
+		names := make([]string, len(set.GetFile()))
+		for i, file := range set.GetFile() {
+			names[i] = file.GetName()
+		}
+		assert.Equal(t, []string{"google/protobuf/descriptor.proto", "filter/filter.proto", "base.proto", "user.proto"}, names)
+	})
+
+	t.Run("Should only add the files themselves without IncludeImports", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "user.proto", "base.proto")
+
+		set := DescriptorSet(descs, DescriptorSetOptions{})
+
+		names := make([]string, len(set.GetFile()))
+		for i, file := range set.GetFile() {
+			names[i] = file.GetName()
+		}
+		assert.Equal(t, []string{"base.proto", "user.proto"}, names)
+	})
+
+	t.Run("Should keep the source info with IncludeSourceInfo", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "base.proto")
+
+		set := DescriptorSet(descs, DescriptorSetOptions{IncludeSourceInfo: true})
+
+		if assert.Len(t, set.GetFile(), 1) {
+			assert.NotNil(t, set.GetFile()[0].GetSourceCodeInfo())
+		}
+	})
+
+	t.Run("Should return an error for a file that is not in the protos", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "base.proto")
+
+		_, err := FromFileDescriptorProtos(DescriptorSet(descs, DescriptorSetOptions{IncludeImports: true}).GetFile(), []string{"user.proto"})
+		assert.EqualError(t, err, `File "user.proto" is not part of the descriptors`)
+	})
+}
+
+func TestLoadDescriptorSets(t *testing.T) {
+	descs, err := Parse([]string{"example/test.proto"}, []string{".."})
+	require.NoError(t, err)
+	// DescriptorSet leaves out the source info, which protoc includes with --include_source_info
+	filterFile := descs[0].GetDependencies()[0]
+	set := &dpb.FileDescriptorSet{File: []*dpb.FileDescriptorProto{
+		filterFile.GetDependencies()[0].AsFileDescriptorProto(),
+		filterFile.AsFileDescriptorProto(),
+		descs[0].AsFileDescriptorProto(),
+	}}
+
+	dir, err := ioutil.TempDir("", "proto-filter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	binary, err := proto.Marshal(set)
+	require.NoError(t, err)
+	binaryPath := filepath.Join(dir, "set.pb")
+	require.NoError(t, ioutil.WriteFile(binaryPath, binary, 0644))
+	json, err := (&jsonpb.Marshaler{}).MarshalToString(set)
+	require.NoError(t, err)
+	jsonPath := filepath.Join(dir, "set.json")
+	require.NoError(t, ioutil.WriteFile(jsonPath, []byte(json), 0644))
+
+	for _, path := range []string{binaryPath, jsonPath} {
+		t.Run("Should load the files with their comments from "+filepath.Base(path), func(t *testing.T) {
+			loaded, err := LoadDescriptorSets([]string{path}, nil)
+			require.NoError(t, err)
+			if assert.Len(t, loaded, 1) {
+				assert.Equal(t, "example/test.proto", loaded[0].GetName())
+				assert.Contains(t, loaded[0].FindService("com.test.TestService").GetSourceInfo().GetLeadingComments(), " My service description\n")
+			}
+
+			result, err := Filter(loaded, Options{Terms: []string{"NA"}})
+			if assert.NoError(t, err) {
+				assert.Nil(t, result.Files[0].FindMessage("com.test.Test").FindFieldByName("jp_string"))
+			}
+		})
+	}
+
+	t.Run("Should only return the named files", func(t *testing.T) {
+		loaded, err := LoadDescriptorSets([]string{binaryPath}, []string{"filter/filter.proto"})
+		if assert.NoError(t, err) && assert.Len(t, loaded, 1) {
+			assert.Equal(t, "filter/filter.proto", loaded[0].GetName())
+		}
+	})
+
+	t.Run("Should return an error for an invalid set", func(t *testing.T) {
+		_, err := LoadDescriptorSets([]string{jsonPath + ".missing"}, nil)
+		assert.Error(t, err)
+		invalidPath := filepath.Join(dir, "invalid.json")
+		require.NoError(t, ioutil.WriteFile(invalidPath, []byte(`{"file": 1}`), 0644))
+		_, err = LoadDescriptorSets([]string{invalidPath}, nil)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), invalidPath+": Invalid FileDescriptorSet")
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package protofilter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/wdullaer/proto-filter/filter"
+)
+
+// Explanation describes how the decision about an element was reached
+type Explanation struct {
+	Element
+	// Annotation is the filter annotation of the element, nil if it has none
+	Annotation *filter.ValueFilter
+	// Decision is the decision of the rules about the element itself
+	Decision Decision
+	// Reason tells which rule decided and which terms matched
+	Reason Reason
+	// Effective is the decision that applies to the element: Decision, or the
+	// decision of its parent if the rules abstained. Abstain means the Policy
+	// applies.
+	Effective Decision
+	// Inherited is true if the effective decision came from a parent
+	Inherited bool
+}
+
+// String formats the explanation as a single line
+func (e Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s: %s", e.Kind, e.Name, e.Effective)
+	switch {
+	case e.Inherited:
+		b.WriteString(", inherited from parent")
+	case e.Effective == Abstain:
+		b.WriteString(", no rule decided")
+	default:
+		fmt.Fprintf(&b, ", decided by %s", e.Reason.Rule)
+	}
+	if len(e.Reason.Terms) != 0 {
+		fmt.Fprintf(&b, ", matched %s", strings.Join(e.Reason.Terms, ", "))
+	}
+	if e.Annotation != nil {
+		fmt.Fprintf(&b, ", annotation {%s}", strings.TrimSpace(proto.CompactTextString(e.Annotation)))
+	}
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package protofilter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const explainTestProto = `
+syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+message Message {
+    option (filter.message).include = "foo";
+    option (filter.message).include = "bar";
+    string name = 1;
+    string secret = 2 [(filter.field).exclude = "foo"];
+    string beta = 3 [(filter.field).expression = "foo && !bar"];
+}
+
+message Other {
+    option (filter.message).include = "baz";
+    string name = 1;
+}
+
+enum Enum {
+    ENUM_DEFAULT = 0;
+}
+`
+
+func explainTestFile(t *testing.T, options Options) map[string]Explanation {
+	descs := parseTestFiles(t, map[string]string{"explain.proto": explainTestProto}, "explain.proto")
+	explanations := make(map[string]Explanation)
+	options.Explain = func(explanation Explanation) {
+		explanations[explanation.Name] = explanation
+	}
+	_, err := Filter(descs, options)
+	require.NoError(t, err)
+	return explanations
+}
+
+func TestExplain(t *testing.T) {
+	t.Run("Should explain the decision of the annotations", func(t *testing.T) {
+		explanations := explainTestFile(t, Options{Terms: []string{"foo"}})
+
+		cases := []struct {
+			name      string
+			effective Decision
+			inherited bool
+			reason    Reason
+			output    string
+		}{
+			{
+				name:      "test.Message",
+				effective: Keep,
+				reason:    Reason{Rule: "annotation include", Terms: []string{"foo"}},
+				output:    `message test.Message: keep, decided by annotation include, matched foo, annotation {include:"foo" include:"bar"}`,
+			},
+			{
+				name:      "test.Message.name",
+				effective: Keep,
+				inherited: true,
+				output:    "field test.Message.name: keep, inherited from parent",
+			},
+			{
+				name:      "test.Message.secret",
+				effective: Drop,
+				reason:    Reason{Rule: "annotation exclude", Terms: []string{"foo"}},
+				output:    `field test.Message.secret: drop, decided by annotation exclude, matched foo, annotation {exclude:"foo"}`,
+			},
+			{
+				name:      "test.Message.beta",
+				effective: Keep,
+				reason:    Reason{Rule: "annotation expression is true"},
+				output:    `field test.Message.beta: keep, decided by annotation expression is true, annotation {expression:"foo && !bar"}`,
+			},
+			{
+				name:      "test.Other",
+				effective: Drop,
+				reason:    Reason{Rule: "annotation include without a matching term"},
+				output:    `message test.Other: drop, decided by annotation include without a matching term, annotation {include:"baz"}`,
+			},
+			{
+				name:      "test.Other.name",
+				effective: Drop,
+				inherited: true,
+				output:    "field test.Other.name: drop, inherited from parent",
+			},
+			{
+				name:      "test.Enum",
+				effective: Abstain,
+				output:    "enum test.Enum: abstain, no rule decided",
+			},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				if assert.Contains(t, explanations, tc.name) {
+					explanation := explanations[tc.name]
+					assert.Equal(t, tc.effective, explanation.Effective)
+					assert.Equal(t, tc.inherited, explanation.Inherited)
+					assert.Equal(t, tc.reason, explanation.Reason)
+					assert.Equal(t, tc.output, explanation.String())
+				}
+			})
+		}
+	})
+
+	t.Run("Should name the custom rule that decided", func(t *testing.T) {
+		enums := RuleFunc(func(element Element) (Decision, error) {
+			if element.Kind == KindEnum {
+				return Drop, nil
+			}
+			return Abstain, nil
+		})
+		explanations := explainTestFile(t, Options{Rules: []Rule{NamedRule("no enums", enums)}})
+
+		assert.Equal(t, Reason{Rule: "no enums"}, explanations["test.Enum"].Reason)
+		assert.Equal(t, Drop, explanations["test.Enum"].Decision)
+	})
+
+	t.Run("Should describe an unnamed rule as a custom rule", func(t *testing.T) {
+		explanations := explainTestFile(t, Options{Rules: []Rule{constantRule(Keep)}})
+
+		assert.Equal(t, Reason{Rule: "custom rule"}, explanations["test.Enum"].Reason)
+	})
+}
+
+func TestNamedRule(t *testing.T) {
+	t.Run("Should decide like the wrapped rule", func(t *testing.T) {
+		if result, err := NamedRule("keep", constantRule(Keep)).Decide(Element{}); assert.NoError(t, err) {
+			assert.Equal(t, Keep, result)
+		}
+		_, err := NamedRule("failing", RuleFunc(func(Element) (Decision, error) {
+			return Abstain, errors.New("failed")
+		})).Decide(Element{})
+		assert.EqualError(t, err, "failed")
+	})
+}
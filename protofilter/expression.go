@@ -0,0 +1,272 @@
+package protofilter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// expression is a parsed ValueFilter.Expression which can be evaluated against
+// the set of terms that is being filtered for
+type expression interface {
+	eval(terms *termSet) bool
+}
+
+type termExpr struct {
+	pattern termPattern
+}
+
+func (e termExpr) eval(terms *termSet) bool {
+	return terms.matches(e.pattern)
+}
+
+type notExpr struct {
+	operand expression
+}
+
+func (e notExpr) eval(terms *termSet) bool {
+	return !e.operand.eval(terms)
+}
+
+type andExpr struct {
+	left, right expression
+}
+
+func (e andExpr) eval(terms *termSet) bool {
+	return e.left.eval(terms) && e.right.eval(terms)
+}
+
+type orExpr struct {
+	left, right expression
+}
+
+func (e orExpr) eval(terms *termSet) bool {
+	return e.left.eval(terms) || e.right.eval(terms)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenTerm
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("`%s` at offset %d", t.value, t.pos)
+}
+
+// keywordKind returns the token kind of a textual operator, or tokenTerm for
+// any other word. The symbolic operators (&&, ||, !) are handled directly by
+// the tokenizer.
+func keywordKind(word string) tokenKind {
+	switch word {
+	case "AND":
+		return tokenAnd
+	case "OR":
+		return tokenOr
+	case "NOT":
+		return tokenNot
+	}
+	return tokenTerm
+}
+
+// isTermRune returns true if r can be part of a term
+func isTermRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.-:/*?", r)
+}
+
+func tokenize(input string) ([]token, error) {
+	runes := []rune(input)
+	tokens := make([]token, 0, len(runes)/2)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, value: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, value: ")", pos: i})
+			i++
+		case r == '!':
+			tokens = append(tokens, token{kind: tokenNot, value: "!", pos: i})
+			i++
+		case r == '&' || r == '|':
+			t, err := tokenizeOperator(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, t)
+			i += len(t.value)
+		case isTermRune(r):
+			t := tokenizeWord(runes, i)
+			tokens = append(tokens, t)
+			i += len([]rune(t.value))
+		default:
+			return nil, fmt.Errorf("unexpected character `%c` at offset %d", r, i)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+// tokenizeOperator returns the `&&` or `||` operator starting at offset i
+func tokenizeOperator(runes []rune, i int) (token, error) {
+	r := runes[i]
+	if i+1 >= len(runes) || runes[i+1] != r {
+		return token{}, fmt.Errorf("unexpected `%c` at offset %d, did you mean `%c%c`?", r, i, r, r)
+	}
+	kind := tokenAnd
+	if r == '|' {
+		kind = tokenOr
+	}
+	return token{kind: kind, value: string([]rune{r, r}), pos: i}, nil
+}
+
+// tokenizeWord returns the term or textual operator starting at offset i
+func tokenizeWord(runes []rune, i int) token {
+	end := i
+	for end < len(runes) && isTermRune(runes[end]) {
+		end++
+	}
+	value := string(runes[i:end])
+	return token{kind: keywordKind(value), value: value, pos: i}
+}
+
+// parser is a recursive descent parser for the following grammar:
+//
+//	or      = and { ("OR" | "||") and }
+//	and     = unary { ("AND" | "&&") unary }
+//	unary   = ("NOT" | "!") unary | primary
+//	primary = "(" or ")" | term
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (expression, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expression, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenTerm:
+		pattern, err := compileTerm(t.value)
+		if err != nil {
+			return nil, err
+		}
+		return termExpr{pattern: pattern}, nil
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, fmt.Errorf("expected `)` to close `(` at offset %d, got %s", t.pos, closing)
+		}
+		return inner, nil
+	default:
+		return nil, fmt.Errorf("expected a term or `(`, got %s", t)
+	}
+}
+
+// parseExpression parses the textual representation of a boolean term
+// expression. It returns an error if the input is not a valid expression.
+func parseExpression(input string) (expression, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	result, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s", t)
+	}
+	return result, nil
+}
+
+// expressionTerms returns the terms used in the expression, in the order they
+// appear in
+func expressionTerms(expr expression) []string {
+	switch e := expr.(type) {
+	case termExpr:
+		return []string{e.pattern.raw}
+	case notExpr:
+		return expressionTerms(e.operand)
+	case andExpr:
+		return append(expressionTerms(e.left), expressionTerms(e.right)...)
+	case orExpr:
+		return append(expressionTerms(e.left), expressionTerms(e.right)...)
+	}
+	return nil
+}
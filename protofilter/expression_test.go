@@ -0,0 +1,108 @@
+package protofilter
+
+import (
+	"testing"
+
+	"github.com/Workiva/go-datastructures/set"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExpression(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		terms  *set.Set
+		output bool
+	}{
+		{
+			name:   "Should match a single term",
+			input:  "foo",
+			terms:  set.New("foo"),
+			output: true,
+		},
+		{
+			name:   "Should not match a single missing term",
+			input:  "foo",
+			terms:  set.New("bar"),
+			output: false,
+		},
+		{
+			name:   "Should evaluate AND",
+			input:  "foo AND bar",
+			terms:  set.New("foo"),
+			output: false,
+		},
+		{
+			name:   "Should evaluate OR",
+			input:  "foo OR bar",
+			terms:  set.New("bar"),
+			output: true,
+		},
+		{
+			name:   "Should evaluate NOT",
+			input:  "NOT foo",
+			terms:  set.New("foo"),
+			output: false,
+		},
+		{
+			name:   "Should support the symbolic operators",
+			input:  "foo && !(bar || baz)",
+			terms:  set.New("foo", "baz"),
+			output: false,
+		},
+		{
+			name:   "Should give AND precedence over OR",
+			input:  "foo OR bar AND baz",
+			terms:  set.New("foo"),
+			output: true,
+		},
+		{
+			name:   "Should respect grouping",
+			input:  "(foo OR bar) AND baz",
+			terms:  set.New("foo"),
+			output: false,
+		},
+		{
+			name:   "Should support hierarchical terms",
+			input:  "partner.acme AND region-eu AND NOT trial",
+			terms:  set.New("partner.acme", "region-eu"),
+			output: true,
+		},
+		{
+			name:   "Should support globs",
+			input:  "partner.* AND NOT partner.globex",
+			terms:  set.New("partner.acme"),
+			output: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if expr, err := parseExpression(tc.input); assert.NoError(t, err) {
+				assert.Equal(t, tc.output, expr.eval(mustNewTermSet(tc.terms)), "Expected `%s` to evaluate to %t", tc.input, tc.output)
+			}
+		})
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+	}{
+		{name: "Should reject an empty expression", input: ""},
+		{name: "Should reject a dangling operator", input: "foo AND"},
+		{name: "Should reject a missing operator", input: "foo bar"},
+		{name: "Should reject an unclosed group", input: "(foo OR bar"},
+		{name: "Should reject an unopened group", input: "foo OR bar)"},
+		{name: "Should reject a single ampersand", input: "foo & bar"},
+		{name: "Should reject unknown characters", input: "foo # bar"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseExpression(tc.input)
+			assert.Error(t, err, "Expected `%s` to be rejected", tc.input)
+		})
+	}
+}
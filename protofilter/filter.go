@@ -0,0 +1,439 @@
+package protofilter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/builder"
+	"github.com/wdullaer/proto-filter/filter"
+)
+
+// filterFile recursively applies the ValueFilter to the proto file and all its
+// contents. It will return `true` if the file is to be removed from the output
+//
+// filterFile mutates the FileBuilder (and child Builders) in place: this
+// simplified the code quite a bit, since there is no convenience method to
+// remove all children from a Builder. Filter only passes it builders created
+// from copies of the input, so the input itself is never modified.
+func filterFile(fileBuilder *builder.FileBuilder, scope filterScope) (bool, error) {
+	fDesc, err := fileBuilder.Build()
+	if err != nil {
+		return false, err
+	}
+	result, scope, err := scope.judge(fDesc)
+	if err != nil {
+		return false, err
+	}
+
+	for _, child := range fileBuilder.GetChildren() {
+		if isExcluded, err := filterChild(child, scope); err != nil {
+			return false, err
+		} else if isExcluded {
+			removeFileChild(fileBuilder, child)
+		}
+	}
+	// Files are namespaces rather than API elements, so the deny policy does
+	// not remove them: it only removes their contents. An excluded file is
+	// kept for the children that opted back in.
+	return result == Drop && len(fileBuilder.GetChildren()) == 0, nil
+}
+
+func removeFileChild(fileBuilder *builder.FileBuilder, child builder.Builder) {
+	switch child.(type) {
+	case *builder.MessageBuilder:
+		fileBuilder.RemoveMessage(child.GetName())
+	case *builder.EnumBuilder:
+		fileBuilder.RemoveEnum(child.GetName())
+	case *builder.ServiceBuilder:
+		fileBuilder.RemoveService(child.GetName())
+	case *builder.FieldBuilder:
+		fileBuilder.RemoveExtension(child.GetName())
+	}
+}
+
+func filterMessage(messageBuilder *builder.MessageBuilder, scope filterScope) (bool, error) {
+	mDesc, err := messageBuilder.Build()
+	if err != nil {
+		return false, err
+	}
+	result, scope, err := scope.judge(mDesc)
+	if err != nil {
+		return false, err
+	}
+
+	for _, child := range messageBuilder.GetChildren() {
+		// Map entries are kept or removed together with their field
+		if entry, ok := child.(*builder.MessageBuilder); ok && entry.Options.GetMapEntry() {
+			continue
+		}
+		if isExcluded, err := filterChild(child, scope); err != nil {
+			return false, err
+		} else if isExcluded {
+			removeMessageChild(messageBuilder, child)
+		}
+	}
+
+	return scope.isRemoved(result, len(messageBuilder.GetChildren()) != 0), nil
+}
+
+func removeMessageChild(messageBuilder *builder.MessageBuilder, child builder.Builder) {
+	switch c := child.(type) {
+	case *builder.FieldBuilder:
+		if c.IsExtension() {
+			messageBuilder.RemoveNestedExtension(c.GetName())
+		} else {
+			if entry := mapEntry(messageBuilder, c); entry != nil {
+				messageBuilder.RemoveNestedMessage(entry.GetName())
+			}
+			messageBuilder.RemoveField(c.GetName())
+		}
+	case *builder.OneOfBuilder:
+		messageBuilder.RemoveOneOf(c.GetName())
+	case *builder.MessageBuilder:
+		messageBuilder.RemoveNestedMessage(c.GetName())
+	case *builder.EnumBuilder:
+		messageBuilder.RemoveNestedEnum(c.GetName())
+	}
+}
+
+// mapEntry returns the nested message holding the key and value types of a
+// map field, or nil if the field is not a map. Builders created from a
+// descriptor keep this message as a sibling of the field, so it has to be
+// removed together with the field.
+func mapEntry(messageBuilder *builder.MessageBuilder, field *builder.FieldBuilder) *builder.MessageBuilder {
+	typeName := field.GetType().GetTypeName()
+	if typeName == "" {
+		return nil
+	}
+	entry := messageBuilder.GetNestedMessage(typeName[strings.LastIndex(typeName, ".")+1:])
+	if entry == nil || !entry.Options.GetMapEntry() || builder.GetFullyQualifiedName(entry) != typeName {
+		return nil
+	}
+	return entry
+}
+
+func filterEnum(enumBuilder *builder.EnumBuilder, scope filterScope) (bool, error) {
+	eDesc, err := enumBuilder.Build()
+	if err != nil {
+		return false, err
+	}
+	result, scope, err := scope.judge(eDesc)
+	if err != nil {
+		return false, err
+	}
+
+	var excluded []builder.Builder
+	var zero *builder.EnumValueBuilder
+	for _, child := range enumBuilder.GetChildren() {
+		if isExcluded, err := filterChild(child, scope); err != nil {
+			return false, err
+		} else if isExcluded {
+			excluded = append(excluded, child)
+		} else if value, ok := child.(*builder.EnumValueBuilder); ok && value.GetNumber() == 0 {
+			zero = value
+		}
+	}
+
+	if !scope.isRemoved(result, len(excluded) != len(enumBuilder.GetChildren())) && zero == nil && eDesc.GetFile().IsProto3() {
+		// A proto3 enum needs its zero value, so the deny policy does not
+		// remove it from an enum that is kept
+		if excluded, err = scope.keepZeroValue(eDesc, excluded); err != nil {
+			return false, err
+		}
+	}
+	kept := len(enumBuilder.GetChildren()) - len(excluded)
+	for _, child := range excluded {
+		removeEnumChild(enumBuilder, child)
+	}
+
+	return scope.isRemoved(result, kept != 0), nil
+}
+
+// keepZeroValue returns the excluded values of the proto3 enum without its
+// zero value. It returns an error if the zero value is excluded by its own
+// annotation, since the enum can not be kept without it. Without an inherited
+// decision, the scope judges the value by its own annotation only.
+func (s filterScope) keepZeroValue(eDesc *desc.EnumDescriptor, excluded []builder.Builder) ([]builder.Builder, error) {
+	zeroDesc := eDesc.GetValues()[0]
+	s.explain = nil
+	s.inherited = Abstain
+	if result, _, err := s.judge(zeroDesc); err != nil {
+		return nil, err
+	} else if result == Drop {
+		return nil, annotationError(zeroDesc, fmt.Errorf("The zero value of a proto3 enum can not be removed while other values of %s are kept", eDesc.GetFullyQualifiedName()))
+	}
+	for i, child := range excluded {
+		if child.GetName() == zeroDesc.GetName() {
+			return append(excluded[:i], excluded[i+1:]...), nil
+		}
+	}
+	return excluded, nil
+}
+
+func removeEnumChild(enumBuilder *builder.EnumBuilder, child builder.Builder) {
+	if c, ok := child.(*builder.EnumValueBuilder); ok {
+		enumBuilder.RemoveValue(c.GetName())
+	}
+}
+
+func filterEnumValue(enumValueBuilder *builder.EnumValueBuilder, scope filterScope) (bool, error) {
+	evDesc, err := enumValueBuilder.Build()
+	if err != nil {
+		return false, err
+	}
+	result, scope, err := scope.judge(evDesc)
+	if err != nil {
+		return false, err
+	}
+
+	// EnumValues cannot have children
+
+	return scope.isRemoved(result, false), nil
+}
+
+func filterService(serviceBuilder *builder.ServiceBuilder, scope filterScope) (bool, error) {
+	sDesc, err := serviceBuilder.Build()
+	if err != nil {
+		return false, err
+	}
+	result, scope, err := scope.judge(sDesc)
+	if err != nil {
+		return false, err
+	}
+
+	for _, child := range serviceBuilder.GetChildren() {
+		if isExcluded, err := filterChild(child, scope); err != nil {
+			return false, err
+		} else if isExcluded {
+			removeServiceChild(serviceBuilder, child)
+		}
+	}
+
+	return scope.isRemoved(result, len(serviceBuilder.GetChildren()) != 0), nil
+}
+
+func removeServiceChild(serviceBuilder *builder.ServiceBuilder, child builder.Builder) {
+	if c, ok := child.(*builder.MethodBuilder); ok {
+		serviceBuilder.RemoveMethod(c.GetName())
+	}
+}
+
+func filterMethod(methodBuilder *builder.MethodBuilder, scope filterScope) (bool, error) {
+	mDesc, err := methodBuilder.Build()
+	if err != nil {
+		return false, err
+	}
+	result, scope, err := scope.judge(mDesc)
+	if err != nil {
+		return false, err
+	}
+
+	// Methods cannot have children
+
+	return scope.isRemoved(result, false), nil
+}
+
+func filterField(fieldBuilder *builder.FieldBuilder, scope filterScope) (bool, error) {
+	fDesc, err := fieldBuilder.Build()
+	if err != nil {
+		return false, err
+	}
+	result, scope, err := scope.judge(fDesc)
+	if err != nil {
+		return false, err
+	}
+
+	// Fields cannot have children
+
+	return scope.isRemoved(result, false), nil
+}
+
+func filterOneOf(oneOfBuilder *builder.OneOfBuilder, scope filterScope) (bool, error) {
+	oDesc, err := oneOfBuilder.Build()
+	if err != nil {
+		return false, err
+	}
+	result, scope, err := scope.judge(oDesc)
+	if err != nil {
+		return false, err
+	}
+
+	for _, child := range oneOfBuilder.GetChildren() {
+		if isExcluded, err := filterChild(child, scope); err != nil {
+			return false, err
+		} else if isExcluded {
+			removeOneOfChild(oneOfBuilder, child)
+		}
+	}
+
+	return scope.isRemoved(result, len(oneOfBuilder.GetChildren()) != 0), nil
+}
+
+func removeOneOfChild(oneOfBuilder *builder.OneOfBuilder, child builder.Builder) {
+	if c, ok := child.(*builder.FieldBuilder); ok {
+		oneOfBuilder.RemoveChoice(c.GetName())
+	}
+}
+
+func filterChild(child builder.Builder, scope filterScope) (bool, error) {
+	switch c := child.(type) {
+	case *builder.MessageBuilder:
+		return filterMessage(c, scope)
+	case *builder.EnumBuilder:
+		return filterEnum(c, scope)
+	case *builder.ServiceBuilder:
+		return filterService(c, scope)
+	case *builder.FieldBuilder:
+		return filterField(c, scope)
+	case *builder.MethodBuilder:
+		return filterMethod(c, scope)
+	case *builder.EnumValueBuilder:
+		return filterEnumValue(c, scope)
+	case *builder.OneOfBuilder:
+		return filterOneOf(c, scope)
+	default:
+		return false, nil
+	}
+}
+
+// evaluateFilter evaluates the filter rules based on the data in the ValueFilter
+//
+// An item is dropped if any term is in Exclude, if the Expression (when set)
+// does not hold, or if Include is not empty and none of its terms are given.
+// It is explicitly kept if it passes these rules and one of its terms is in
+// Include or its Expression holds.
+func evaluateFilter(filterVal *filter.ValueFilter, terms *termSet) (Decision, error) {
+	result, _, err := explainFilter(filterVal, terms)
+	return result, err
+}
+
+// explainFilter is evaluateFilter, but also returns which of the rules
+// decided and the annotation terms that matched
+func explainFilter(filterVal *filter.ValueFilter, terms *termSet) (Decision, Reason, error) {
+	if terms == nil || terms.Len() == 0 {
+		return Abstain, Reason{}, nil
+	}
+	excluded, err := matchingTerms(filterVal.GetExclude(), terms)
+	if err != nil {
+		return Abstain, Reason{}, err
+	}
+	if len(excluded) != 0 {
+		return Drop, Reason{Rule: "exclude", Terms: excluded}, nil
+	}
+	result, reason := Abstain, Reason{}
+	if filterVal.Expression != nil {
+		expr, err := terms.parseExpression(filterVal.GetExpression())
+		if err != nil {
+			return Abstain, Reason{}, fmt.Errorf("Invalid filter expression %q: %v", filterVal.GetExpression(), err)
+		}
+		if !expr.eval(terms) {
+			return Drop, Reason{Rule: "expression is false"}, nil
+		}
+		result, reason = Keep, Reason{Rule: "expression is true"}
+	}
+	included, err := matchingTerms(filterVal.GetInclude(), terms)
+	if err != nil {
+		return Abstain, Reason{}, err
+	}
+	if len(included) != 0 {
+		return Keep, Reason{Rule: "include", Terms: included}, nil
+	}
+	// If Include is empty we don't want to exclude the item by default.
+	// If Include is not empty, we should only include it if is explicitly matching
+	if len(filterVal.Include) != 0 {
+		return Drop, Reason{Rule: "include without a matching term"}, nil
+	}
+	return result, reason, nil
+}
+
+// matchingTerms returns the annotation terms in items which match the terms
+func matchingTerms(items []string, terms *termSet) ([]string, error) {
+	var result []string
+	for _, item := range items {
+		if matched, err := terms.matchesTerm(item); err != nil {
+			return nil, err
+		} else if matched {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// filterScope holds the state that is threaded through the recursive filter
+// functions
+type filterScope struct {
+	rule   Rule
+	policy Policy
+	// inherited is the effective decision of the closest ancestor that has one
+	inherited Decision
+	// parents are the descriptors enclosing the element, starting at the file
+	parents []desc.Descriptor
+	// explain is called with the explanation of every decision, if it is set
+	explain func(Explanation)
+}
+
+// judge applies the rule to an element within the scope. It returns the
+// effective decision for the element and the scope in which its children
+// should be judged.
+//
+// An element for which the rule abstains inherits the decision of its parent.
+// The children of a dropped element are judged too, so a child can opt back in
+// by deciding Keep itself.
+func (s filterScope) judge(d desc.Descriptor) (Decision, filterScope, error) {
+	element := Element{
+		Descriptor: d,
+		Kind:       kindOf(d),
+		Name:       d.GetFullyQualifiedName(),
+		Parents:    s.parents,
+	}
+	result, reason, err := decideWithReason(s.rule, element)
+	if err != nil {
+		return Abstain, s, annotationError(d, err)
+	}
+	if s.explain != nil {
+		annotation, _ := annotationOf(d)
+		s.explain(Explanation{
+			Element:    element,
+			Annotation: annotation,
+			Decision:   result,
+			Reason:     reason,
+			Effective:  s.effective(result),
+			Inherited:  result == Abstain && s.inherited != Abstain,
+		})
+	}
+	result = s.effective(result)
+	s.inherited = result
+	// Copy the parents, so an element passed to a rule never changes
+	s.parents = append(append(make([]desc.Descriptor, 0, len(s.parents)+1), s.parents...), d)
+	return result, s, nil
+}
+
+// effective returns the decision that applies to an element for which the
+// rule decided result: elements without a decision inherit it
+func (s filterScope) effective(result Decision) Decision {
+	if result == Abstain {
+		return s.inherited
+	}
+	return result
+}
+
+// isRemoved returns whether an element with the given effective decision
+// should be removed, once its children have been filtered. A dropped element,
+// and under the deny policy an element without a decision, is only kept to
+// hold the descendants that explicitly opted in.
+func (s filterScope) isRemoved(result Decision, hasChildren bool) bool {
+	switch result {
+	case Drop:
+		return !hasChildren
+	case Keep:
+		return false
+	}
+	return s.policy == PolicyDeny && !hasChildren
+}
+
+// annotationError adds the file and the fully qualified name of the element
+// that could not be judged to err
+func annotationError(d desc.Descriptor, err error) error {
+	return fmt.Errorf("%s: %s: %v", d.GetFile().GetName(), d.GetFullyQualifiedName(), err)
+}
@@ -1,4 +1,4 @@
-package main
+package protofilter
 
 import (
 	"fmt"
@@ -13,56 +13,104 @@ import (
 	"github.com/wdullaer/proto-filter/filter"
 )
 
-func TestIsExcluded(t *testing.T) {
+// newTestScope is a test helper that creates an allow policy filterScope for terms
+func newTestScope(terms *set.Set) filterScope {
+	return filterScope{rule: &annotationRule{terms: mustNewTermSet(terms)}}
+}
+
+func TestEvaluateFilter(t *testing.T) {
 	cases := []struct {
 		name   string
 		input  *filter.ValueFilter
 		terms  *set.Set
-		output bool
+		output Decision
 	}{
 		{
-			name:   "Should return `false` when ValueFilter and Terms are empty",
+			name:   "Should return `Abstain` when ValueFilter and Terms are empty",
 			input:  &filter.ValueFilter{},
 			terms:  set.New(),
-			output: false,
+			output: Abstain,
 		},
 		{
-			name:   "Should return `false` when ValueFilter is empty and Terms is not",
+			name:   "Should return `Abstain` when ValueFilter is empty and Terms is not",
 			input:  &filter.ValueFilter{},
 			terms:  set.New("foo"),
-			output: false,
+			output: Abstain,
 		},
 		{
-			name:   "Should return `false` when term is in ValueFilter.Include",
+			name:   "Should return `Keep` when term is in ValueFilter.Include",
 			input:  &filter.ValueFilter{Include: []string{"foo"}},
 			terms:  set.New("foo"),
-			output: false,
+			output: Keep,
 		},
 		{
-			name:   "Should return `true` when term is in ValueFilter.Exclude",
+			name:   "Should return `Drop` when term is in ValueFilter.Exclude",
 			input:  &filter.ValueFilter{Exclude: []string{"foo"}},
 			terms:  set.New("foo"),
-			output: true,
+			output: Drop,
 		},
 		{
-			name:   "Should return `true` when terms are in both ValueFilter.Exclude and ValueFilter.Include",
+			name:   "Should return `Drop` when terms are in both ValueFilter.Exclude and ValueFilter.Include",
 			input:  &filter.ValueFilter{Exclude: []string{"foo"}, Include: []string{"bar"}},
 			terms:  set.New("foo", "bar"),
-			output: true,
+			output: Drop,
 		},
 		{
-			name:   "Should return `true` when terms are not in ValueFilter.Include, but ValueFilter.Include is not empty",
+			name:   "Should return `Drop` when terms are not in ValueFilter.Include, but ValueFilter.Include is not empty",
 			input:  &filter.ValueFilter{Exclude: []string{}, Include: []string{"foo"}},
 			terms:  set.New("bar"),
-			output: true,
+			output: Drop,
+		},
+		{
+			name:   "Should return `Drop` when a glob in ValueFilter.Exclude matches a term",
+			input:  &filter.ValueFilter{Exclude: []string{"partner.*"}},
+			terms:  set.New("partner.acme"),
+			output: Drop,
+		},
+		{
+			name:   "Should return `Keep` when a glob term matches ValueFilter.Include",
+			input:  &filter.ValueFilter{Include: []string{"internal.ops"}},
+			terms:  set.New("internal.*"),
+			output: Keep,
+		},
+		{
+			name:   "Should return `Keep` when the Expression holds",
+			input:  &filter.ValueFilter{Expression: proto.String("partner AND eu AND NOT trial")},
+			terms:  set.New("partner", "eu"),
+			output: Keep,
+		},
+		{
+			name:   "Should return `Drop` when the Expression does not hold",
+			input:  &filter.ValueFilter{Expression: proto.String("partner AND eu AND NOT trial")},
+			terms:  set.New("partner", "eu", "trial"),
+			output: Drop,
+		},
+		{
+			name:   "Should return `Drop` when the Expression holds, but a term is in ValueFilter.Exclude",
+			input:  &filter.ValueFilter{Expression: proto.String("partner"), Exclude: []string{"trial"}},
+			terms:  set.New("partner", "trial"),
+			output: Drop,
+		},
+		{
+			name:   "Should return `Drop` when the Expression does not hold, but a term is in ValueFilter.Include",
+			input:  &filter.ValueFilter{Expression: proto.String("partner"), Include: []string{"eu"}},
+			terms:  set.New("eu"),
+			output: Drop,
 		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			assert.Equal(t, tc.output, isExcluded(tc.input, tc.terms))
+			if result, err := evaluateFilter(tc.input, mustNewTermSet(tc.terms)); assert.NoError(t, err) {
+				assert.Equal(t, tc.output, result)
+			}
 		})
 	}
+
+	t.Run("Should return an error when the Expression is invalid", func(t *testing.T) {
+		_, err := evaluateFilter(&filter.ValueFilter{Expression: proto.String("partner AND")}, mustNewTermSet(set.New("partner")))
+		assert.Error(t, err)
+	})
 }
 
 func getEnumValueFilter(exclude []string, include []string) *dpb.EnumValueOptions {
@@ -115,7 +163,7 @@ func TestFilterEnumValue(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// EnumValue must be part of an enum for filterEnumValue to work
 			builder.NewEnum("enum").AddValue(tc.input)
-			if result, err := filterEnumValue(tc.input, tc.terms); assert.NoError(t, err) {
+			if result, err := filterEnumValue(tc.input, newTestScope(tc.terms)); assert.NoError(t, err) {
 				assert.Equal(t, tc.output, result)
 			}
 		})
@@ -135,6 +183,18 @@ func getFieldFilter(exclude []string, include []string) *dpb.FieldOptions {
 	return result
 }
 
+func getFieldExpressionFilter(expression string) *dpb.FieldOptions {
+	filt := &filter.ValueFilter{
+		Expression: proto.String(expression),
+	}
+
+	result := &dpb.FieldOptions{}
+	if err := proto.SetExtension(result, filter.E_Field, filt); err != nil {
+		fmt.Println(err)
+	}
+	return result
+}
+
 // TestFilterEnumValue does not exhaustively test the filter logic (that happens in TestIsExcluded)
 // The test cases are there to test the data transformation logic that happens
 func TestFilterField(t *testing.T) {
@@ -166,13 +226,27 @@ func TestFilterField(t *testing.T) {
 			output:  true,
 			isError: false,
 		},
+		{
+			name:    "Should return an error if input has extension with an invalid Expression",
+			input:   builder.NewField("field", builder.FieldTypeString()).SetOptions(getFieldExpressionFilter("foo OR (bar")),
+			terms:   set.New("foo"),
+			output:  false,
+			isError: true,
+		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			// EnumValue must be part of an enum for filterEnumValue to work
 			builder.NewMessage("message").AddField(tc.input)
-			if result, err := filterField(tc.input, tc.terms); assert.NoError(t, err) {
+			result, err := filterField(tc.input, newTestScope(tc.terms))
+			if tc.isError {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), "message.field", "Expected the error to point to the annotated element")
+				}
+				return
+			}
+			if assert.NoError(t, err) {
 				assert.Equal(t, tc.output, result)
 			}
 		})
@@ -230,7 +304,7 @@ func TestFilterMethod(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// EnumValue must be part of an enum for filterEnumValue to work
 			builder.NewService("service").AddMethod(tc.input)
-			if result, err := filterMethod(tc.input, tc.terms); assert.NoError(t, err) {
+			if result, err := filterMethod(tc.input, newTestScope(tc.terms)); assert.NoError(t, err) {
 				assert.Equal(t, tc.output, result)
 			}
 		})
@@ -320,7 +394,7 @@ func TestFilterService(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			if result, err := filterService(tc.input, tc.terms); assert.NoError(t, err) {
+			if result, err := filterService(tc.input, newTestScope(tc.terms)); assert.NoError(t, err) {
 				assert.Equal(t, tc.output, result)
 				children := make([]string, len(tc.input.GetChildren()))
 				for i, v := range tc.input.GetChildren() {
@@ -414,7 +488,7 @@ func TestFilterEnum(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			if result, err := filterEnum(tc.input, tc.terms); assert.NoError(t, err) {
+			if result, err := filterEnum(tc.input, newTestScope(tc.terms)); assert.NoError(t, err) {
 				assert.Equal(t, tc.output, result)
 				children := make([]string, len(tc.input.GetChildren()))
 				for i, v := range tc.input.GetChildren() {
@@ -510,7 +584,7 @@ func TestFilterOneOf(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// one_of must be part of a message for .Build() to work
 			builder.NewMessage("message").AddOneOf(tc.input)
-			if result, err := filterOneOf(tc.input, tc.terms); assert.NoError(t, err) {
+			if result, err := filterOneOf(tc.input, newTestScope(tc.terms)); assert.NoError(t, err) {
 				assert.Equal(t, tc.output, result)
 				children := make([]string, len(tc.input.GetChildren()))
 				for i, v := range tc.input.GetChildren() {
@@ -648,7 +722,7 @@ func TestFilterMessage(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			if result, err := filterMessage(tc.input, tc.terms); assert.NoError(t, err) {
+			if result, err := filterMessage(tc.input, newTestScope(tc.terms)); assert.NoError(t, err) {
 				assert.Equal(t, tc.output, result)
 				children := make([]string, len(tc.input.GetChildren()))
 				for i, v := range tc.input.GetChildren() {
@@ -786,7 +860,7 @@ func TestFilterFile(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			if result, err := filterFile(tc.input, tc.terms); assert.NoError(t, err) {
+			if result, err := filterFile(tc.input, newTestScope(tc.terms)); assert.NoError(t, err) {
 				assert.Equal(t, tc.output, result)
 				children := make([]string, len(tc.input.GetChildren()))
 				for i, v := range tc.input.GetChildren() {
@@ -797,3 +871,231 @@ func TestFilterFile(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterFileDenyPolicy(t *testing.T) {
+	cases := []struct {
+		name             string
+		input            *builder.FileBuilder
+		terms            *set.Set
+		expectedChildren []string
+		output           bool
+	}{
+		{
+			name:             "Should keep a file without the extension",
+			input:            builder.NewFile("file"),
+			terms:            set.New("foo"),
+			expectedChildren: []string{},
+			output:           false,
+		},
+		{
+			name: "Should remove children without the extension",
+			input: builder.NewFile("file").
+				AddMessage(builder.NewMessage("message1")).
+				AddMessage(builder.NewMessage("message2").SetOptions(getMessageFilter([]string{}, []string{"foo"}))),
+			terms:            set.New("foo"),
+			expectedChildren: []string{"message2"},
+			output:           false,
+		},
+		{
+			name: "Should remove children that only exclude other terms",
+			input: builder.NewFile("file").
+				AddMessage(builder.NewMessage("message1").SetOptions(getMessageFilter([]string{"bar"}, []string{}))).
+				AddMessage(builder.NewMessage("message2").SetOptions(getMessageFilter([]string{}, []string{"foo"}))),
+			terms:            set.New("foo"),
+			expectedChildren: []string{"message2"},
+			output:           false,
+		},
+		{
+			name: "Should keep children when the file includes the term",
+			input: builder.NewFile("file").SetOptions(getFileFilter([]string{}, []string{"foo"})).
+				AddMessage(builder.NewMessage("message1")).
+				AddMessage(builder.NewMessage("message2").SetOptions(getMessageFilter([]string{"foo"}, []string{}))),
+			terms:            set.New("foo"),
+			expectedChildren: []string{"message1"},
+			output:           false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			scope := filterScope{rule: &annotationRule{terms: mustNewTermSet(tc.terms)}, policy: PolicyDeny}
+			if result, err := filterFile(tc.input, scope); assert.NoError(t, err) {
+				assert.Equal(t, tc.output, result)
+				children := make([]string, len(tc.input.GetChildren()))
+				for i, v := range tc.input.GetChildren() {
+					children[i] = v.GetName()
+				}
+				assert.ElementsMatch(t, tc.expectedChildren, children)
+			}
+		})
+	}
+
+	t.Run("Should keep the zero value of a proto3 enum that is kept", func(t *testing.T) {
+		descs := parseTestFiles(t, map[string]string{"enum.proto": `
+syntax = "proto3";
+package test;
+import "filter/filter.proto";
+enum E {
+    E_ZERO = 0;
+    E_ONE = 1 [(filter.enum_value).include = "sdk"];
+    E_TWO = 2;
+}
+enum Hidden {
+    HIDDEN_ZERO = 0;
+}
+`}, "enum.proto")
+
+		result, err := Filter(descs, Options{Terms: []string{"sdk"}, Policy: PolicyDeny})
+		if assert.NoError(t, err) {
+			values := result.Files[0].FindEnum("test.E").GetValues()
+			if assert.Len(t, values, 2) {
+				assert.Equal(t, "E_ZERO", values[0].GetName())
+				assert.Equal(t, "E_ONE", values[1].GetName())
+			}
+			assert.Nil(t, result.Files[0].FindEnum("test.Hidden"))
+		}
+	})
+
+	t.Run("Should return an error if the zero value of a kept proto3 enum is excluded", func(t *testing.T) {
+		descs := parseTestFiles(t, map[string]string{"enum.proto": `
+syntax = "proto3";
+package test;
+import "filter/filter.proto";
+enum E {
+    E_ZERO = 0 [(filter.enum_value).exclude = "sdk"];
+    E_ONE = 1;
+}
+`}, "enum.proto")
+
+		_, err := Filter(descs, Options{Terms: []string{"sdk"}})
+		assert.EqualError(t, err, "enum.proto: test.E.E_ZERO: The zero value of a proto3 enum can not be removed while other values of test.E are kept")
+	})
+
+	t.Run("Should keep unannotated descendants of an element that includes the term", func(t *testing.T) {
+		input := builder.NewMessage("message").SetOptions(getMessageFilter([]string{}, []string{"foo"})).
+			AddField(builder.NewField("field1", builder.FieldTypeString())).
+			AddNestedEnum(builder.NewEnum("enum").AddValue(builder.NewEnumValue("VALUE")))
+		builder.NewFile("file").AddMessage(input)
+
+		scope := filterScope{rule: &annotationRule{terms: mustNewTermSet(set.New("foo"))}, policy: PolicyDeny}
+		if result, err := filterMessage(input, scope); assert.NoError(t, err) {
+			assert.False(t, result)
+			assert.Len(t, input.GetChildren(), 2)
+			assert.Len(t, input.GetNestedEnum("enum").GetChildren(), 1)
+		}
+	})
+}
+
+func TestFilterScopeJudge(t *testing.T) {
+	cases := []struct {
+		name      string
+		options   *dpb.MessageOptions
+		inherited Decision
+		output    Decision
+	}{
+		{
+			name:      "Should return `Abstain` without an annotation or an inherited decision",
+			options:   nil,
+			inherited: Abstain,
+			output:    Abstain,
+		},
+		{
+			name:      "Should inherit the decision of the parent without an annotation",
+			options:   nil,
+			inherited: Keep,
+			output:    Keep,
+		},
+		{
+			name:      "Should inherit the decision of the parent if the annotation has no opinion",
+			options:   getMessageFilter([]string{"bar"}, []string{}),
+			inherited: Keep,
+			output:    Keep,
+		},
+		{
+			name:      "Should override the decision of the parent with an annotation that excludes the term",
+			options:   getMessageFilter([]string{"foo"}, []string{}),
+			inherited: Keep,
+			output:    Drop,
+		},
+		{
+			name:      "Should override the absence of a decision with an annotation that includes the term",
+			options:   getMessageFilter([]string{}, []string{"foo"}),
+			inherited: Abstain,
+			output:    Keep,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mDesc, err := builder.NewMessage("message").SetOptions(tc.options).Build()
+			if !assert.NoError(t, err) {
+				return
+			}
+			scope := newTestScope(set.New("foo"))
+			scope.inherited = tc.inherited
+			if result, childScope, err := scope.judge(mDesc); assert.NoError(t, err) {
+				assert.Equal(t, tc.output, result)
+				assert.Equal(t, tc.output, childScope.inherited, "Expected the children to inherit the effective decision")
+			}
+		})
+	}
+}
+
+func TestFilterMessageInheritance(t *testing.T) {
+	t.Run("Should keep a message without a decision that holds a child which opted in under the deny policy", func(t *testing.T) {
+		input := builder.NewMessage("message").
+			AddField(builder.NewField("field1", builder.FieldTypeString())).
+			AddField(builder.NewField("field2", builder.FieldTypeString()).SetOptions(getFieldFilter([]string{}, []string{"foo"})))
+		builder.NewFile("file").AddMessage(input)
+
+		scope := filterScope{rule: &annotationRule{terms: mustNewTermSet(set.New("foo"))}, policy: PolicyDeny}
+		if result, err := filterMessage(input, scope); assert.NoError(t, err) {
+			assert.False(t, result)
+			if assert.Len(t, input.GetChildren(), 1) {
+				assert.Equal(t, "field2", input.GetChildren()[0].GetName())
+			}
+		}
+	})
+
+	t.Run("Should pass the decision down through multiple levels under the deny policy", func(t *testing.T) {
+		nested := builder.NewMessage("nested").
+			AddField(builder.NewField("field1", builder.FieldTypeString())).
+			AddField(builder.NewField("field2", builder.FieldTypeString()).SetOptions(getFieldFilter([]string{"foo"}, []string{})))
+		input := builder.NewMessage("message").SetOptions(getMessageFilter([]string{}, []string{"foo"})).
+			AddNestedMessage(nested)
+		builder.NewFile("file").AddMessage(input)
+
+		scope := filterScope{rule: &annotationRule{terms: mustNewTermSet(set.New("foo"))}, policy: PolicyDeny}
+		if result, err := filterMessage(input, scope); assert.NoError(t, err) {
+			assert.False(t, result)
+			assert.Len(t, input.GetChildren(), 1)
+			if assert.Len(t, nested.GetChildren(), 1) {
+				assert.Equal(t, "field1", nested.GetChildren()[0].GetName())
+			}
+		}
+	})
+
+	t.Run("Should keep an excluded message that holds a child which opted back in", func(t *testing.T) {
+		input := builder.NewMessage("message").SetOptions(getMessageFilter([]string{"foo"}, []string{})).
+			AddField(builder.NewField("field1", builder.FieldTypeString()).SetOptions(getFieldFilter([]string{}, []string{"foo"}))).
+			AddField(builder.NewField("field2", builder.FieldTypeString()))
+		builder.NewFile("file").AddMessage(input)
+
+		if result, err := filterMessage(input, newTestScope(set.New("foo"))); assert.NoError(t, err) {
+			assert.False(t, result)
+			if assert.Len(t, input.GetChildren(), 1) {
+				assert.Equal(t, "field1", input.GetChildren()[0].GetName())
+			}
+		}
+	})
+
+	t.Run("Should remove an excluded message without a child that opted back in", func(t *testing.T) {
+		input := builder.NewMessage("message").SetOptions(getMessageFilter([]string{"foo"}, []string{})).
+			AddField(builder.NewField("field1", builder.FieldTypeString()).SetOptions(getFieldFilter([]string{}, []string{"bar"})))
+		builder.NewFile("file").AddMessage(input)
+
+		if result, err := filterMessage(input, newTestScope(set.New("foo"))); assert.NoError(t, err) {
+			assert.True(t, result)
+		}
+	})
+}
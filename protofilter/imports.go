@@ -0,0 +1,255 @@
+package protofilter
+
+import (
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+// pruneImports returns a copy of the filtered file descriptor which only
+// imports the files that are still used by its elements: the files defining
+// the types they refer to and the custom options they set.
+//
+// original is the descriptor the file was filtered from. Its public and weak
+// imports are restored, since builders do not retain them. A public import is
+// always kept, as it is part of what the file exports, unless the imported
+// file was removed. removed holds the names of the files the filter removed.
+func pruneImports(fd *desc.FileDescriptor, original *desc.FileDescriptor, removed map[string]struct{}) (*desc.FileDescriptor, error) {
+	available := make(map[string]*desc.FileDescriptor)
+	for _, dep := range append(original.GetDependencies(), fd.GetDependencies()...) {
+		available[dep.GetName()] = dep
+	}
+	used := usedFiles(fd)
+
+	originalProto := original.AsFileDescriptorProto()
+	public := make(map[string]bool)
+	for _, index := range originalProto.GetPublicDependency() {
+		public[originalProto.GetDependency()[index]] = true
+	}
+	weak := make(map[string]bool)
+	for _, index := range originalProto.GetWeakDependency() {
+		weak[originalProto.GetDependency()[index]] = true
+	}
+
+	fdProto := proto.Clone(fd.AsFileDescriptorProto()).(*dpb.FileDescriptorProto)
+	fdProto.Dependency = nil
+	fdProto.PublicDependency = nil
+	fdProto.WeakDependency = nil
+	deps := make([]*desc.FileDescriptor, 0, len(available))
+	covered := make(map[string]struct{})
+
+	addDependency := func(dep *desc.FileDescriptor) {
+		index := int32(len(fdProto.Dependency))
+		if public[dep.GetName()] {
+			fdProto.PublicDependency = append(fdProto.PublicDependency, index)
+		}
+		if weak[dep.GetName()] {
+			fdProto.WeakDependency = append(fdProto.WeakDependency, index)
+		}
+		fdProto.Dependency = append(fdProto.Dependency, dep.GetName())
+		deps = append(deps, dep)
+		for name := range exportedFiles(dep) {
+			covered[name] = struct{}{}
+		}
+	}
+
+	for _, name := range originalProto.GetDependency() {
+		dep, ok := available[name]
+		if !ok {
+			continue
+		}
+		if _, isRemoved := removed[name]; isRemoved {
+			continue
+		}
+		if public[name] || exportsAny(dep, used) {
+			addDependency(dep)
+		}
+	}
+	// Types can also be resolved through imports that were added while
+	// building the filtered file
+	for name := range used {
+		if _, ok := covered[name]; ok {
+			continue
+		}
+		if dep, ok := available[name]; ok {
+			addDependency(dep)
+		}
+	}
+
+	return desc.CreateFileDescriptor(fdProto, deps...)
+}
+
+// exportedFiles returns the names of the file and of all the files it makes
+// available through (transitive) public imports
+func exportedFiles(fd *desc.FileDescriptor) map[string]struct{} {
+	result := map[string]struct{}{fd.GetName(): {}}
+	for _, dep := range fd.GetPublicDependencies() {
+		for name := range exportedFiles(dep) {
+			result[name] = struct{}{}
+		}
+	}
+	return result
+}
+
+// exportsAny returns true if importing fd makes any of the used files available
+func exportsAny(fd *desc.FileDescriptor, used map[string]struct{}) bool {
+	for name := range exportedFiles(fd) {
+		if _, ok := used[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// usedFiles returns the names of all files (other than fd itself) that define
+// a type or a custom option used by one of the elements in fd
+func usedFiles(fd *desc.FileDescriptor) map[string]struct{} {
+	u := fileUsage{fd: fd, result: make(map[string]struct{})}
+	u.useOptions(fd.GetFileOptions())
+	for _, md := range fd.GetMessageTypes() {
+		u.useMessage(md)
+	}
+	for _, ed := range fd.GetEnumTypes() {
+		u.useEnum(ed)
+	}
+	for _, ext := range fd.GetExtensions() {
+		u.useField(ext)
+	}
+	for _, sd := range fd.GetServices() {
+		u.useOptions(sd.GetServiceOptions())
+		for _, method := range sd.GetMethods() {
+			u.use(method.GetInputType())
+			u.use(method.GetOutputType())
+			u.useOptions(method.GetMethodOptions())
+		}
+	}
+	return u.result
+}
+
+// fileUsage collects the files used by the elements of fd
+type fileUsage struct {
+	fd     *desc.FileDescriptor
+	result map[string]struct{}
+}
+
+func (u fileUsage) use(d desc.Descriptor) {
+	if d != nil && d.GetFile().GetName() != u.fd.GetName() {
+		u.result[d.GetFile().GetName()] = struct{}{}
+	}
+}
+
+func (u fileUsage) useOptions(options proto.Message) {
+	for _, ext := range optionExtensions(u.fd, options) {
+		u.use(ext)
+	}
+}
+
+func (u fileUsage) useField(field *desc.FieldDescriptor) {
+	if msgType := field.GetMessageType(); msgType != nil {
+		u.use(msgType)
+	}
+	if enumType := field.GetEnumType(); enumType != nil {
+		u.use(enumType)
+	}
+	if field.IsExtension() {
+		u.use(field.GetOwner())
+	}
+	u.useOptions(field.GetFieldOptions())
+}
+
+func (u fileUsage) useEnum(ed *desc.EnumDescriptor) {
+	u.useOptions(ed.GetEnumOptions())
+	for _, value := range ed.GetValues() {
+		u.useOptions(value.GetEnumValueOptions())
+	}
+}
+
+func (u fileUsage) useMessage(md *desc.MessageDescriptor) {
+	u.useOptions(md.GetMessageOptions())
+	for _, field := range md.GetFields() {
+		u.useField(field)
+	}
+	for _, oneOf := range md.GetOneOfs() {
+		u.useOptions(oneOf.GetOneOfOptions())
+	}
+	for _, extRange := range md.AsDescriptorProto().GetExtensionRange() {
+		u.useOptions(extRange.GetOptions())
+	}
+	for _, ext := range md.GetNestedExtensions() {
+		u.useField(ext)
+	}
+	for _, nested := range md.GetNestedMessageTypes() {
+		u.useMessage(nested)
+	}
+	for _, ed := range md.GetNestedEnumTypes() {
+		u.useEnum(ed)
+	}
+}
+
+// optionExtensions returns the descriptors of the custom options (extensions)
+// that are set in the options message, as far as they can be resolved from
+// the dependencies of fd
+func optionExtensions(fd *desc.FileDescriptor, options proto.Message) []*desc.FieldDescriptor {
+	if options == nil || reflect.ValueOf(options).IsNil() {
+		return nil
+	}
+	md, err := desc.LoadMessageDescriptorForMessage(options)
+	if err != nil {
+		return nil
+	}
+	// Custom options are usually not registered with the proto runtime, in
+	// which case they end up as unknown fields
+	dm := dynamic.NewMessage(md)
+	if err := dm.ConvertFrom(options); err != nil {
+		return nil
+	}
+	numbers := dm.GetUnknownFields()
+	for _, ext := range dm.GetKnownExtensions() {
+		numbers = append(numbers, ext.GetNumber())
+	}
+
+	var result []*desc.FieldDescriptor
+	for _, number := range numbers {
+		if ext := findExtension(fd, md.GetFullyQualifiedName(), number, map[string]struct{}{}); ext != nil {
+			result = append(result, ext)
+		}
+	}
+	return result
+}
+
+// findExtension searches fd and its transitive dependencies for the extension
+// of the extendee with the given field number
+func findExtension(fd *desc.FileDescriptor, extendee string, number int32, seen map[string]struct{}) *desc.FieldDescriptor {
+	if _, ok := seen[fd.GetName()]; ok {
+		return nil
+	}
+	seen[fd.GetName()] = struct{}{}
+
+	var exts []*desc.FieldDescriptor
+	exts = append(exts, fd.GetExtensions()...)
+	var collect func(md *desc.MessageDescriptor)
+	collect = func(md *desc.MessageDescriptor) {
+		exts = append(exts, md.GetNestedExtensions()...)
+		for _, nested := range md.GetNestedMessageTypes() {
+			collect(nested)
+		}
+	}
+	for _, md := range fd.GetMessageTypes() {
+		collect(md)
+	}
+	for _, ext := range exts {
+		if ext.GetNumber() == number && ext.GetOwner().GetFullyQualifiedName() == extendee {
+			return ext
+		}
+	}
+
+	for _, dep := range fd.GetDependencies() {
+		if ext := findExtension(dep, extendee, number, seen); ext != nil {
+			return ext
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package protofilter
+
+import (
+	"testing"
+
+	"github.com/Workiva/go-datastructures/set"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var importsTestFiles = map[string]string{
+	"types.proto": `
+syntax = "proto3";
+package types;
+
+message Type {}
+`,
+	"unused.proto": `
+syntax = "proto3";
+package unused;
+
+message Unused {}
+`,
+	"options.proto": `
+syntax = "proto3";
+package options;
+
+import "google/protobuf/descriptor.proto";
+
+extend google.protobuf.FieldOptions {
+    string note = 50000;
+}
+`,
+	"wrapper.proto": `
+syntax = "proto3";
+package types;
+
+import public "types.proto";
+`,
+	"weak.proto": `
+syntax = "proto3";
+package weak;
+
+message Weak {}
+`,
+	"hidden.proto": `
+syntax = "proto3";
+package hidden;
+
+import "filter/filter.proto";
+
+option (filter.file).exclude = "foo";
+
+message Hidden {}
+`,
+	"main.proto": `
+syntax = "proto3";
+package main;
+
+import "filter/filter.proto";
+import "unused.proto";
+import "options.proto";
+import "wrapper.proto";
+import weak "weak.proto";
+import public "hidden.proto";
+import public "types.proto";
+
+message Message {
+    types.Type type = 1;
+    unused.Unused unused = 2 [(filter.field).exclude = "foo"];
+    string noted = 3 [(options.note) = "note"];
+    weak.Weak weak = 4;
+    string kept = 5 [(filter.field).include = "foo"];
+}
+`,
+}
+
+// pruneTestFile is a test helper which filters the input files for the terms
+// and returns the pruned descriptor of main.proto
+func pruneTestFile(t *testing.T, terms *set.Set) *desc.FileDescriptor {
+	names := []string{"main.proto", "hidden.proto"}
+	descs := parseTestFiles(t, importsTestFiles, names...)
+	filtered := filterTestFiles(t, descs, terms)
+
+	removed := make(map[string]struct{})
+	for _, name := range names {
+		if _, ok := filtered[name]; !ok {
+			removed[name] = struct{}{}
+		}
+	}
+
+	fd, err := filtered["main.proto"].Build()
+	require.NoError(t, err)
+	fd, err = pruneImports(fd, descs[0], removed)
+	require.NoError(t, err)
+	return fd
+}
+
+func TestPruneImports(t *testing.T) {
+	t.Run("Should only keep the imports which are still used", func(t *testing.T) {
+		fd := pruneTestFile(t, set.New("foo"))
+		assert.Equal(t, []string{"filter/filter.proto", "options.proto", "wrapper.proto", "weak.proto", "types.proto"}, fd.AsFileDescriptorProto().GetDependency())
+	})
+
+	t.Run("Should keep the imports of types which were not removed", func(t *testing.T) {
+		fd := pruneTestFile(t, set.New("bar"))
+		assert.Contains(t, fd.AsFileDescriptorProto().GetDependency(), "unused.proto")
+		assert.Contains(t, fd.AsFileDescriptorProto().GetDependency(), "hidden.proto")
+	})
+
+	t.Run("Should preserve public and weak imports", func(t *testing.T) {
+		fd := pruneTestFile(t, set.New("foo"))
+		assert.Equal(t, []int32{4}, fd.AsFileDescriptorProto().GetPublicDependency())
+		assert.Equal(t, []int32{3}, fd.AsFileDescriptorProto().GetWeakDependency())
+	})
+
+	t.Run("Should drop public imports of removed files", func(t *testing.T) {
+		fd := pruneTestFile(t, set.New("foo"))
+		assert.NotContains(t, fd.AsFileDescriptorProto().GetDependency(), "hidden.proto")
+	})
+}
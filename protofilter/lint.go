@@ -0,0 +1,202 @@
+package protofilter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/wdullaer/proto-filter/filter"
+)
+
+// Severity tells how serious a Problem is
+type Severity int
+
+const (
+	// SeverityWarning is a problem that is probably a mistake
+	SeverityWarning Severity = iota
+	// SeverityError is a problem that certainly is a mistake, or that makes
+	// filtering fail
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Problem is an issue with a filter annotation found by Lint
+type Problem struct {
+	Severity Severity
+	// Name is the fully qualified name of the annotated element
+	Name string
+	// File, Line and Column are the position of the element, see Removal
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// String formats the problem as `file:line:column: severity: name: message`
+func (p Problem) String() string {
+	position := p.File
+	if p.Line != 0 {
+		position = fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s: %s: %s: %s", position, p.Severity, p.Name, p.Message)
+}
+
+// Lint checks the filter annotations in the files and returns the problems it
+// finds, in the order of the files. It reports:
+//   - terms that are empty, have surrounding whitespace or are invalid patterns
+//   - expressions that can not be parsed
+//   - terms that are both included and excluded by the same annotation
+//   - terms that are listed more than once
+//   - terms that are included, while an enclosing element excludes them, so
+//     the enclosing element is only kept as a container for them
+//
+// The elements are visited in the same way Filter visits them.
+func Lint(descs []*desc.FileDescriptor) ([]Problem, error) {
+	return checkAnnotations(descs, lintAnnotation)
+}
+
+// reportFunc records a problem with the element that is being checked
+type reportFunc func(severity Severity, format string, args ...interface{})
+
+// checkAnnotations calls check with the annotation of every annotated element
+// in the files, visiting them in the same way Filter does, and returns the
+// problems it reported
+func checkAnnotations(descs []*desc.FileDescriptor, check func(element Element, annotation *filter.ValueFilter, report reportFunc)) ([]Problem, error) {
+	checker := &annotationChecker{originals: make(map[string]desc.Descriptor), check: check}
+	for _, fd := range descs {
+		for _, child := range childDescriptors(fd) {
+			collectOriginals(child, checker.originals)
+		}
+	}
+	if _, err := Filter(descs, Options{Rules: []Rule{checker}}); err != nil {
+		return nil, err
+	}
+	return checker.problems, nil
+}
+
+// annotationChecker is a Rule which never decides, but checks the annotation
+// of every element it is applied to
+type annotationChecker struct {
+	// originals maps fully qualified names onto the descriptors that were
+	// parsed, since only those have source info
+	originals map[string]desc.Descriptor
+	check     func(element Element, annotation *filter.ValueFilter, report reportFunc)
+	problems  []Problem
+}
+
+// collectOriginals adds d and all of its descendants to result, keyed by
+// their fully qualified name
+func collectOriginals(d desc.Descriptor, result map[string]desc.Descriptor) {
+	result[d.GetFullyQualifiedName()] = d
+	for _, child := range childDescriptors(d) {
+		collectOriginals(child, result)
+	}
+}
+
+func (c *annotationChecker) Decide(element Element) (Decision, error) {
+	annotation, err := annotationOf(element.Descriptor)
+	if err != nil || annotation == nil {
+		return Abstain, err
+	}
+	c.check(element, annotation, func(severity Severity, format string, args ...interface{}) {
+		problem := Problem{
+			Severity: severity,
+			Name:     element.Name,
+			File:     element.Descriptor.GetFile().GetName(),
+			Message:  fmt.Sprintf(format, args...),
+		}
+		if original, ok := c.originals[element.Name]; ok {
+			problem.Line, problem.Column = position(original)
+		}
+		c.problems = append(c.problems, problem)
+	})
+	return Abstain, nil
+}
+
+// lintAnnotation reports the problems Lint looks for in the annotation
+func lintAnnotation(element Element, annotation *filter.ValueFilter, report reportFunc) {
+	excluded := make(map[string]struct{})
+	for _, term := range annotation.GetExclude() {
+		excluded[term] = struct{}{}
+	}
+	for _, list := range []struct {
+		name  string
+		terms []string
+	}{{"include", annotation.GetInclude()}, {"exclude", annotation.GetExclude()}} {
+		seen := make(map[string]struct{})
+		for _, term := range list.terms {
+			if _, ok := seen[term]; ok {
+				report(SeverityWarning, "Term %q is listed more than once in %s", term, list.name)
+				continue
+			}
+			seen[term] = struct{}{}
+			if msg := checkTerm(term); len(msg) != 0 {
+				report(SeverityError, "%s", msg)
+			}
+		}
+	}
+	for _, term := range annotation.GetInclude() {
+		if _, ok := excluded[term]; ok {
+			report(SeverityError, "Term %q is both included and excluded", term)
+		}
+	}
+	if annotation.Expression != nil {
+		if _, err := parseExpression(annotation.GetExpression()); err != nil {
+			report(SeverityError, "Invalid filter expression %q: %v", annotation.GetExpression(), err)
+		}
+	}
+	lintParents(element, annotation, report)
+}
+
+// lintParents reports the terms the annotation includes while an enclosing
+// element excludes them. The element is kept for those terms, but the
+// enclosing element is reduced to a container for it, which is rarely intended.
+func lintParents(element Element, annotation *filter.ValueFilter, report reportFunc) {
+	for _, parent := range element.Parents {
+		parentAnnotation, err := annotationOf(parent)
+		if err != nil || parentAnnotation == nil {
+			continue
+		}
+		for _, term := range annotation.GetInclude() {
+			for _, exclude := range parentAnnotation.GetExclude() {
+				if excludes(exclude, term) {
+					report(SeverityWarning, "Includes %q, which is excluded by %s, so that element is only kept as a container for it", term, parent.GetFullyQualifiedName())
+				}
+			}
+		}
+	}
+}
+
+// checkTerm returns why the annotation term is invalid, or an empty string
+func checkTerm(term string) string {
+	if len(strings.TrimSpace(term)) == 0 {
+		return "Empty term"
+	}
+	if strings.TrimSpace(term) != term {
+		return fmt.Sprintf("Term %q has leading or trailing whitespace", term)
+	}
+	if err := ValidateTerm(term); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// excludes returns true if the exclude term matches every term the include
+// term matches
+func excludes(exclude string, include string) bool {
+	if exclude == include {
+		return true
+	}
+	excludePattern, err := compileTerm(exclude)
+	if err != nil || excludePattern.re == nil {
+		return false
+	}
+	includePattern, err := compileTerm(include)
+	return err == nil && includePattern.re == nil && excludePattern.re.MatchString(include)
+}
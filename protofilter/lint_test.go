@@ -0,0 +1,69 @@
+package protofilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const lintTestProto = `syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+message Message {
+    option (filter.message).exclude = "partner.*";
+    option (filter.message).exclude = "JP";
+    string both = 1 [(filter.field).include = "NA", (filter.field).exclude = "NA"];
+    string space = 2 [(filter.field).include = "NA "];
+    string twice = 3 [(filter.field).exclude = "NA", (filter.field).exclude = "NA"];
+    string regex = 4 [(filter.field).include = "/[/"];
+    string expression = 5 [(filter.field).expression = "NA &&"];
+    string partner = 6 [(filter.field).include = "partner.acme"];
+    string jp = 7 [(filter.field).include = "JP"];
+    string partners = 8 [(filter.field).include = "partner.*"];
+    string ok = 9 [(filter.field).include = "EU"];
+}
+`
+
+func TestLint(t *testing.T) {
+	t.Run("Should report the problems with their position", func(t *testing.T) {
+		descs := parseTestFiles(t, map[string]string{"lint.proto": lintTestProto}, "lint.proto")
+
+		problems, err := Lint(descs)
+		require.NoError(t, err)
+
+		lines := make([]string, len(problems))
+		for i, problem := range problems {
+			lines[i] = problem.String()
+		}
+		assert.Equal(t, []string{
+			`lint.proto:9:5: error: test.Message.both: Term "NA" is both included and excluded`,
+			`lint.proto:10:5: error: test.Message.space: Term "NA " has leading or trailing whitespace`,
+			`lint.proto:11:5: warning: test.Message.twice: Term "NA" is listed more than once in exclude`,
+			`lint.proto:12:5: error: test.Message.regex: Invalid regular expression in term "/[/": error parsing regexp: missing closing ]: ` + "`[`",
+			`lint.proto:13:5: error: test.Message.expression: Invalid filter expression "NA &&": expected a term or ` + "`(`" + `, got end of expression`,
+			`lint.proto:14:5: warning: test.Message.partner: Includes "partner.acme", which is excluded by test.Message, so that element is only kept as a container for it`,
+			`lint.proto:15:5: warning: test.Message.jp: Includes "JP", which is excluded by test.Message, so that element is only kept as a container for it`,
+			`lint.proto:16:5: warning: test.Message.partners: Includes "partner.*", which is excluded by test.Message, so that element is only kept as a container for it`,
+		}, lines)
+	})
+
+	cases := []struct {
+		name    string
+		exclude string
+		include string
+		output  bool
+	}{
+		{name: "Should match identical terms", exclude: "NA", include: "NA", output: true},
+		{name: "Should match a literal with a pattern", exclude: "partner.*", include: "partner.acme", output: true},
+		{name: "Should not match a pattern with a literal", exclude: "partner.acme", include: "partner.*", output: false},
+		{name: "Should not match different literals", exclude: "NA", include: "JP", output: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.output, excludes(tc.exclude, tc.include))
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package protofilter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/wdullaer/proto-filter/filter"
+)
+
+// VisibilityMatrix tells for every element of the files which of the terms
+// keep it
+type VisibilityMatrix struct {
+	Terms []string
+	// Rows are in the order of the files, every element followed by its
+	// children
+	Rows []MatrixRow
+}
+
+// MatrixRow is an element in a VisibilityMatrix
+type MatrixRow struct {
+	Kind    Kind
+	Name    string
+	File    string
+	Package string
+	// Depth is the number of enclosing elements, 0 for the elements at the
+	// top of a file
+	Depth int
+	// Visible tells for every term if the element is kept when filtering for it
+	Visible []bool
+}
+
+// UsedTerms returns the sorted literal terms used in the annotations of the
+// files, including the terms in expressions. Globs and regular expressions
+// are not terms a filter is run for, so they are left out.
+func UsedTerms(descs []*desc.FileDescriptor) ([]string, error) {
+	used := make(map[string]struct{})
+	_, err := checkAnnotations(descs, func(element Element, annotation *filter.ValueFilter, report reportFunc) {
+		terms := append(append([]string(nil), annotation.GetInclude()...), annotation.GetExclude()...)
+		if expr, err := parseExpression(annotation.GetExpression()); annotation.Expression != nil && err == nil {
+			terms = append(terms, expressionTerms(expr)...)
+		}
+		for _, term := range terms {
+			if pattern, err := compileTerm(term); err == nil && pattern.re == nil {
+				used[term] = struct{}{}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(used))
+	for term := range used {
+		result = append(result, term)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// NewVisibilityMatrix filters the files once for every term, with the other
+// options as given, and records which elements are kept. Options.Terms is
+// ignored. Elements that refer to a type removed for a term are hidden for it,
+// so Options.Dangling is always DanglingCascade.
+func NewVisibilityMatrix(descs []*desc.FileDescriptor, terms []string, options Options) (*VisibilityMatrix, error) {
+	options.Dangling = DanglingCascade
+	matrix := &VisibilityMatrix{Terms: terms}
+	index := make(map[string]int)
+	var visit func(d desc.Descriptor, depth int)
+	visit = func(d desc.Descriptor, depth int) {
+		index[d.GetFullyQualifiedName()] = len(matrix.Rows)
+		matrix.Rows = append(matrix.Rows, MatrixRow{
+			Kind:    kindOf(d),
+			Name:    d.GetFullyQualifiedName(),
+			File:    d.GetFile().GetName(),
+			Package: d.GetFile().GetPackage(),
+			Depth:   depth,
+			Visible: make([]bool, len(terms)),
+		})
+		for _, child := range childDescriptors(d) {
+			visit(child, depth+1)
+		}
+	}
+	for _, fd := range descs {
+		for _, child := range childDescriptors(fd) {
+			visit(child, 0)
+		}
+	}
+
+	for i, term := range terms {
+		options.Terms = []string{term}
+		result, err := Filter(descs, options)
+		if err != nil {
+			return nil, fmt.Errorf("Term %q: %v", term, err)
+		}
+		for j := range matrix.Rows {
+			matrix.Rows[j].Visible[i] = true
+		}
+		for _, removal := range result.Removed {
+			if j, ok := index[removal.Name]; ok && removal.Kind != KindFile {
+				matrix.Rows[j].Visible[i] = false
+			}
+		}
+	}
+	return matrix, nil
+}
@@ -0,0 +1,81 @@
+package protofilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const matrixTestProto = `syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+message Message {
+    string name = 1;
+    string na = 2 [(filter.field).include = "NA"];
+    string jp = 3 [(filter.field).exclude = "NA", (filter.field).exclude = "partner.*"];
+    map<string, string> labels = 4 [(filter.field).expression = "EU || JP"];
+}
+
+service Service {
+    option (filter.service).include = "NA";
+    rpc Method(Message) returns (Message);
+}
+`
+
+func TestUsedTerms(t *testing.T) {
+	t.Run("Should return the sorted literal terms of the annotations", func(t *testing.T) {
+		descs := parseTestFiles(t, map[string]string{"matrix.proto": matrixTestProto}, "matrix.proto")
+
+		terms, err := UsedTerms(descs)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"EU", "JP", "NA"}, terms)
+		}
+	})
+}
+
+func TestNewVisibilityMatrix(t *testing.T) {
+	t.Run("Should record which terms keep every element", func(t *testing.T) {
+		descs := parseTestFiles(t, map[string]string{"matrix.proto": matrixTestProto}, "matrix.proto")
+
+		matrix, err := NewVisibilityMatrix(descs, []string{"EU", "NA"}, Options{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"EU", "NA"}, matrix.Terms)
+		assert.Equal(t, []MatrixRow{
+			{Kind: KindMessage, Name: "test.Message", File: "matrix.proto", Package: "test", Depth: 0, Visible: []bool{true, true}},
+			{Kind: KindField, Name: "test.Message.name", File: "matrix.proto", Package: "test", Depth: 1, Visible: []bool{true, true}},
+			{Kind: KindField, Name: "test.Message.na", File: "matrix.proto", Package: "test", Depth: 1, Visible: []bool{false, true}},
+			{Kind: KindField, Name: "test.Message.jp", File: "matrix.proto", Package: "test", Depth: 1, Visible: []bool{true, false}},
+			{Kind: KindField, Name: "test.Message.labels", File: "matrix.proto", Package: "test", Depth: 1, Visible: []bool{true, false}},
+			{Kind: KindService, Name: "test.Service", File: "matrix.proto", Package: "test", Depth: 0, Visible: []bool{false, true}},
+			{Kind: KindMethod, Name: "test.Service.Method", File: "matrix.proto", Package: "test", Depth: 1, Visible: []bool{false, true}},
+		}, matrix.Rows)
+	})
+	t.Run("Should hide the elements that refer to a type removed for a term", func(t *testing.T) {
+		descs := parseTestFiles(t, map[string]string{"dangling.proto": `syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+message Hidden {
+    option (filter.message).exclude = "NA";
+}
+
+message Message {
+    Hidden hidden = 1;
+}
+`}, "dangling.proto")
+
+		matrix, err := NewVisibilityMatrix(descs, []string{"EU", "NA"}, Options{Dangling: DanglingFail})
+		require.NoError(t, err)
+
+		assert.Equal(t, []MatrixRow{
+			{Kind: KindMessage, Name: "test.Hidden", File: "dangling.proto", Package: "test", Depth: 0, Visible: []bool{true, false}},
+			{Kind: KindMessage, Name: "test.Message", File: "dangling.proto", Package: "test", Depth: 0, Visible: []bool{true, true}},
+			{Kind: KindField, Name: "test.Message.hidden", File: "dangling.proto", Package: "test", Depth: 1, Visible: []bool{true, false}},
+		}, matrix.Rows)
+	})
+}
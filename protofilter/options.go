@@ -0,0 +1,95 @@
+package protofilter
+
+import "fmt"
+
+// Options determine how Filter filters the files
+type Options struct {
+	// Terms are the terms to filter for: literals, globs or regular expressions
+	Terms []string
+	// Rules are applied before the filter annotations: the annotations only
+	// decide about an element if all of the rules abstain
+	Rules []Rule
+	// Policy applies to elements without an annotation matching the terms
+	Policy Policy
+	// Dangling determines what happens with kept elements that refer to a
+	// removed type
+	Dangling DanglingMode
+	// Shake removes all types which can not be reached from a kept service,
+	// extension or one of the Roots
+	Shake bool
+	// Roots are the fully qualified names (or patterns) of types that Shake
+	// should always keep
+	Roots []string
+	// Reserve adds reserved numbers for removed fields and enum values
+	Reserve bool
+	// ReserveNames also reserves their names, which implies Reserve
+	ReserveNames bool
+	// Clean removes the filter annotations from the output
+	Clean bool
+	// Explain is called with the explanation of the decision for every
+	// element that is visited, in the order they are visited
+	Explain func(Explanation)
+}
+
+// Policy determines what happens with elements that are not covered by an
+// annotation matching one of the terms
+type Policy int
+
+const (
+	// PolicyAllow keeps elements unless an annotation removes them
+	PolicyAllow Policy = iota
+	// PolicyDeny removes elements unless an annotation on the element or one
+	// of its ancestors explicitly includes one of the terms
+	PolicyDeny
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyAllow:
+		return "allow"
+	case PolicyDeny:
+		return "deny"
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// ParsePolicy converts the name of a policy into a Policy
+func ParsePolicy(name string) (Policy, error) {
+	for _, policy := range []Policy{PolicyAllow, PolicyDeny} {
+		if policy.String() == name {
+			return policy, nil
+		}
+	}
+	return PolicyAllow, fmt.Errorf("Unknown policy %q, expected `allow` or `deny`", name)
+}
+
+// DanglingMode determines what happens with kept elements that refer to a type
+// which was removed
+type DanglingMode int
+
+const (
+	// DanglingFail aborts with a report of all dangling references
+	DanglingFail DanglingMode = iota
+	// DanglingCascade removes every element that refers to a removed type
+	DanglingCascade
+)
+
+func (m DanglingMode) String() string {
+	switch m {
+	case DanglingFail:
+		return "fail"
+	case DanglingCascade:
+		return "cascade"
+	}
+	return fmt.Sprintf("DanglingMode(%d)", int(m))
+}
+
+// ParseDanglingMode converts the name of a dangling mode into a DanglingMode
+func ParseDanglingMode(name string) (DanglingMode, error) {
+	for _, mode := range []DanglingMode{DanglingFail, DanglingCascade} {
+		if mode.String() == name {
+			return mode, nil
+		}
+	}
+	return DanglingFail, fmt.Errorf("Unknown dangling mode %q, expected `fail` or `cascade`", name)
+}
@@ -0,0 +1,38 @@
+package protofilter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePolicy(t *testing.T) {
+	for _, policy := range []Policy{PolicyAllow, PolicyDeny} {
+		t.Run(fmt.Sprintf("Should parse `%s`", policy), func(t *testing.T) {
+			if result, err := ParsePolicy(policy.String()); assert.NoError(t, err) {
+				assert.Equal(t, policy, result)
+			}
+		})
+	}
+
+	t.Run("Should return an error for an unknown policy", func(t *testing.T) {
+		_, err := ParsePolicy("maybe")
+		assert.Error(t, err)
+	})
+}
+
+func TestParseDanglingMode(t *testing.T) {
+	for _, mode := range []DanglingMode{DanglingFail, DanglingCascade} {
+		t.Run(fmt.Sprintf("Should parse `%s`", mode), func(t *testing.T) {
+			if result, err := ParseDanglingMode(mode.String()); assert.NoError(t, err) {
+				assert.Equal(t, mode, result)
+			}
+		})
+	}
+
+	t.Run("Should return an error for an unknown mode", func(t *testing.T) {
+		_, err := ParseDanglingMode("ignore")
+		assert.Error(t, err)
+	})
+}
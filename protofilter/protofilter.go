@@ -0,0 +1,202 @@
+// Package protofilter removes the elements from proto files that are not
+// meant for a particular audience, based on the filter annotations defined in
+// filter/filter.proto.
+//
+// Filter works on parsed descriptors and returns new descriptors, it never
+// touches the filesystem:
+//
+//	descs, err := protofilter.Parse([]string{"api.proto"}, []string{"."})
+//	...
+//	result, err := protofilter.Filter(descs, protofilter.Options{Terms: []string{"NA"}})
+package protofilter
+
+import (
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/builder"
+	"github.com/jhump/protoreflect/desc/protoparse"
+)
+
+// Result holds the outcome of Filter
+type Result struct {
+	// Files are the filtered descriptors of the files that were kept, in the
+	// order of the input
+	Files []*desc.FileDescriptor
+	// RemovedTypes are the sorted fully qualified names of the types removed
+	// by Options.Shake
+	RemovedTypes []string
+	// Removed are all elements of the input which are not part of Files, in
+	// the order of the input
+	Removed []Removal
+}
+
+// Parse parses the proto files at the given paths, resolving imports against
+// the import paths. The result can be filtered any number of times.
+func Parse(paths []string, importPaths []string) ([]*desc.FileDescriptor, error) {
+	parser := protoparse.Parser{
+		ImportPaths:           importPaths,
+		InferImportPaths:      true,
+		IncludeSourceCodeInfo: true,
+	}
+	return parser.ParseFiles(paths...)
+}
+
+// FilterFiles parses the proto files at the given paths and filters them
+func FilterFiles(paths []string, importPaths []string, options Options) (*Result, error) {
+	descs, err := Parse(paths, importPaths)
+	if err != nil {
+		return nil, err
+	}
+	return Filter(descs, options)
+}
+
+// Filter applies the filter annotations in the files to the terms in the
+// options and returns the filtered files. The descriptors are treated as
+// immutable: the result is built from copies, so the same descriptors can be
+// filtered multiple times and compared with the result. Filter can be called
+// from multiple goroutines at the same time, also for the same descriptors.
+//
+// Types in the files may only refer to other types in the files, or in their
+// dependencies: removing a type that is used by a dependency of the files
+// is not detected.
+func Filter(descs []*desc.FileDescriptor, options Options) (*Result, error) {
+	rule, err := newRule(options)
+	if err != nil {
+		return nil, err
+	}
+
+	builders, err := newFileBuilders(descs)
+	if err != nil {
+		return nil, err
+	}
+	decisions := make(map[string]Explanation)
+	explain := func(explanation Explanation) {
+		decisions[explanation.Name] = explanation
+		if options.Explain != nil {
+			options.Explain(explanation)
+		}
+	}
+	filtered, err := filterFiles(builders, filterScope{rule: rule, policy: options.Policy, explain: explain})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := resolveDanglingReferences(descs, filtered, options.Dangling); err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	if options.Shake {
+		roots, err := compileRoots(options.Roots)
+		if err != nil {
+			return nil, err
+		}
+		result.RemovedTypes = shakeTypes(filtered, roots)
+	}
+
+	if options.Reserve || options.ReserveNames {
+		reserveRemoved(descs, filtered, options.ReserveNames)
+	}
+
+	if options.Clean {
+		for _, fileBuilder := range filtered {
+			cleanAnnotations(fileBuilder)
+		}
+	}
+
+	if result.Files, err = buildFiles(descs, filtered); err != nil {
+		return nil, err
+	}
+
+	shaken := make(map[string]struct{}, len(result.RemovedTypes))
+	for _, name := range result.RemovedTypes {
+		shaken[name] = struct{}{}
+	}
+	result.Removed = findRemovals(descs, result.Files, decisions, shaken, options.Policy)
+	return result, nil
+}
+
+// filterFiles filters the file builders in place, and returns the ones that
+// were kept by name
+func filterFiles(builders []*builder.FileBuilder, scope filterScope) (map[string]*builder.FileBuilder, error) {
+	filtered := make(map[string]*builder.FileBuilder, len(builders))
+	for _, fileBuilder := range builders {
+		if removed, err := filterFile(fileBuilder, scope); err != nil {
+			return nil, err
+		} else if !removed {
+			filtered[fileBuilder.GetName()] = fileBuilder
+		}
+	}
+	return filtered, nil
+}
+
+// compileRoots compiles the patterns of Options.Roots
+func compileRoots(roots []string) ([]termPattern, error) {
+	result := make([]termPattern, len(roots))
+	for i, root := range roots {
+		var err error
+		if result[i], err = compileTerm(root); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// buildFiles builds the filtered files, in the order of descs, and prunes the
+// imports they no longer use
+func buildFiles(descs []*desc.FileDescriptor, filtered map[string]*builder.FileBuilder) ([]*desc.FileDescriptor, error) {
+	removedFiles := make(map[string]struct{})
+	for _, fdesc := range descs {
+		if _, ok := filtered[fdesc.GetName()]; !ok {
+			removedFiles[fdesc.GetName()] = struct{}{}
+		}
+	}
+
+	result := make([]*desc.FileDescriptor, 0, len(filtered))
+	for _, fdesc := range descs {
+		if fileBuilder, ok := filtered[fdesc.GetName()]; ok {
+			fDesc, err := fileBuilder.Build()
+			if err != nil {
+				return nil, err
+			}
+			if fDesc, err = pruneImports(fDesc, fdesc, removedFiles); err != nil {
+				return nil, err
+			}
+			result = append(result, fDesc)
+		}
+	}
+	return result, nil
+}
+
+// newRule returns the Rule which applies the rules of the options, followed by
+// the annotations for the terms
+func newRule(options Options) (Rule, error) {
+	annotations, err := NewAnnotationRule(options.Terms)
+	if err != nil {
+		return nil, err
+	}
+	return Chain(append(append([]Rule(nil), options.Rules...), annotations)...), nil
+}
+
+// newFileBuilders creates a fresh set of builders for the descriptors, which
+// can be modified without affecting the descriptors or other builders.
+//
+// builder.FromFile shares the options, reserved ranges and other parts of the
+// descriptor protos with the builders, so the builders are created from deep
+// copies of the descriptors. The copies still refer to the original
+// dependencies, which are never modified.
+func newFileBuilders(descs []*desc.FileDescriptor) ([]*builder.FileBuilder, error) {
+	result := make([]*builder.FileBuilder, len(descs))
+	for i, fdesc := range descs {
+		fdProto := proto.Clone(fdesc.AsFileDescriptorProto()).(*dpb.FileDescriptorProto)
+		fdCopy, err := desc.CreateFileDescriptor(fdProto, fdesc.GetDependencies()...)
+		if err != nil {
+			return nil, err
+		}
+		if result[i], err = builder.FromFile(fdCopy); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
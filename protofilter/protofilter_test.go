@@ -0,0 +1,199 @@
+package protofilter
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const protofilterTestProto = `
+syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+message Message {
+    reserved 10, 12, 14;
+    string common = 1;
+    string foo = 2 [(filter.field).include = "foo"];
+    string bar = 3 [(filter.field).include = "bar"];
+}
+
+message Unused {
+    option (filter.message).include = "foo";
+}
+`
+
+const protofilterTestRemovedProto = `
+syntax = "proto3";
+package test.removed;
+
+import "filter/filter.proto";
+
+option (filter.file).include = "bar";
+
+message Removed {}
+`
+
+func TestFilter(t *testing.T) {
+	files := map[string]string{"filter.proto": protofilterTestProto, "removed.proto": protofilterTestRemovedProto}
+
+	t.Run("Should filter the same descriptors multiple times", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "filter.proto")
+
+		foo, err := Filter(descs, Options{Terms: []string{"foo"}, Reserve: true})
+		require.NoError(t, err)
+		bar, err := Filter(descs, Options{Terms: []string{"bar"}, Reserve: true})
+		require.NoError(t, err)
+
+		fooMessage := foo.Files[0].FindMessage("test.Message")
+		barMessage := bar.Files[0].FindMessage("test.Message")
+		assert.NotNil(t, fooMessage.FindFieldByName("foo"))
+		assert.Nil(t, fooMessage.FindFieldByName("bar"))
+		assert.Nil(t, barMessage.FindFieldByName("foo"))
+		assert.NotNil(t, barMessage.FindFieldByName("bar"))
+
+		fooReserved := fooMessage.AsDescriptorProto().GetReservedRange()
+		barReserved := barMessage.AsDescriptorProto().GetReservedRange()
+		if assert.Len(t, fooReserved, 4) && assert.Len(t, barReserved, 4) {
+			assert.Equal(t, int32(3), fooReserved[3].GetStart())
+			assert.Equal(t, int32(2), barReserved[3].GetStart())
+		}
+		original := descs[0].FindMessage("test.Message")
+		assert.Len(t, original.AsDescriptorProto().GetReservedRange(), 3)
+		assert.Len(t, original.GetFields(), 3)
+	})
+
+	t.Run("Should not share any state between the result and the input", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "filter.proto")
+
+		result, err := Filter(descs, Options{Terms: []string{"foo"}})
+		require.NoError(t, err)
+		options := result.Files[0].FindMessage("test.Message").FindFieldByName("foo").GetFieldOptions()
+		options.Deprecated = proto.Bool(true)
+
+		original := descs[0].FindMessage("test.Message").FindFieldByName("foo").GetFieldOptions()
+		assert.False(t, original.GetDeprecated())
+	})
+
+	t.Run("Should be safe to call concurrently for the same descriptors", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "filter.proto", "removed.proto")
+		allTerms := [][]string{{"foo"}, {"bar"}, {"foo", "bar"}, {"baz"}}
+
+		expected := make([]*Result, len(allTerms))
+		for i, terms := range allTerms {
+			result, err := Filter(descs, Options{Terms: terms, Reserve: true, Clean: true})
+			require.NoError(t, err)
+			expected[i] = result
+		}
+
+		var wg sync.WaitGroup
+		results := make([]*Result, 4*len(allTerms))
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], _ = Filter(descs, Options{Terms: allTerms[i%len(allTerms)], Reserve: true, Clean: true})
+			}(i)
+		}
+		wg.Wait()
+
+		for i, result := range results {
+			want := expected[i%len(allTerms)]
+			if assert.NotNil(t, result) && assert.Len(t, result.Files, len(want.Files)) {
+				for j, fd := range result.Files {
+					assert.True(t, proto.Equal(want.Files[j].AsFileDescriptorProto(), fd.AsFileDescriptorProto()))
+				}
+			}
+		}
+	})
+
+	t.Run("Should only return the files that were kept, in order", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "removed.proto", "filter.proto")
+
+		result, err := Filter(descs, Options{Terms: []string{"foo"}})
+		if assert.NoError(t, err) && assert.Len(t, result.Files, 1) {
+			assert.Equal(t, "filter.proto", result.Files[0].GetName())
+		}
+
+		result, err = Filter(descs, Options{Terms: []string{"bar"}})
+		if assert.NoError(t, err) && assert.Len(t, result.Files, 2) {
+			assert.Equal(t, "removed.proto", result.Files[0].GetName())
+			assert.Equal(t, "filter.proto", result.Files[1].GetName())
+		}
+	})
+
+	t.Run("Should return the types that were removed by shaking", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "filter.proto")
+
+		result, err := Filter(descs, Options{Terms: []string{"foo"}, Shake: true, Roots: []string{"test.Message"}})
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"test.Unused"}, result.RemovedTypes)
+		}
+	})
+
+	t.Run("Should return an error for an invalid term", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "filter.proto")
+
+		_, err := Filter(descs, Options{Terms: []string{"/(foo/"}})
+		assert.Error(t, err)
+	})
+}
+
+const protofilterTestMapProto = `
+syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+message Denied {
+    string name = 1;
+    map<string, string> labels = 2 [(filter.field) = {include: ["X"]}];
+}
+
+message Excluded {
+    option (filter.message).exclude = "X";
+    string name = 1;
+    map<string, string> labels = 2 [(filter.field) = {include: ["X"]}];
+}
+`
+
+func TestFilterMapEntries(t *testing.T) {
+	cases := []struct {
+		name    string
+		message string
+		policy  Policy
+	}{
+		{name: "Should keep the entry of a map field that opted in under the deny policy", message: "test.Denied", policy: PolicyDeny},
+		{name: "Should keep the entry of a map field that opted in below an excluded message", message: "test.Excluded", policy: PolicyAllow},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			descs := parseTestFiles(t, map[string]string{"map.proto": protofilterTestMapProto}, "map.proto")
+
+			result, err := Filter(descs, Options{Terms: []string{"X"}, Policy: tc.policy})
+			require.NoError(t, err)
+			message := result.Files[0].FindMessage(tc.message)
+			require.NotNil(t, message)
+			assert.Nil(t, message.FindFieldByName("name"))
+			if field := message.FindFieldByName("labels"); assert.NotNil(t, field) {
+				assert.True(t, field.IsMap())
+				assert.Equal(t, tc.message+".LabelsEntry", field.GetMessageType().GetFullyQualifiedName())
+			}
+		})
+	}
+}
+
+func TestFilterFiles(t *testing.T) {
+	t.Run("Should parse and filter the files", func(t *testing.T) {
+		result, err := FilterFiles([]string{"example/test.proto"}, []string{".."}, Options{Terms: []string{"NA"}})
+		if assert.NoError(t, err) && assert.Len(t, result.Files, 1) {
+			assert.NotNil(t, result.Files[0].FindMessage("com.test.Test").FindFieldByName("na_string"))
+			assert.Nil(t, result.Files[0].FindMessage("com.test.Test").FindFieldByName("jp_string"))
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package protofilter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/builder"
+)
+
+// danglingReference is a kept element that refers to a type which was removed
+// by the filter
+type danglingReference struct {
+	user     desc.Descriptor
+	typeName string
+}
+
+func (r danglingReference) String() string {
+	return fmt.Sprintf("%s: %s refers to removed type %s", sourcePosition(r.user), r.user.GetFullyQualifiedName(), r.typeName)
+}
+
+// resolveDanglingReferences finds every kept field, map field, extension and
+// method in the filtered files that refers to a message or enum which was
+// removed. Depending on the mode it either removes those elements from their
+// builders, or returns an error listing all of them.
+//
+// originals are the descriptors the builders were created from and filtered
+// maps the name of every file that was kept onto its (filtered) builder.
+// Names are used to correlate both, since removed builders no longer know
+// their fully qualified name.
+func resolveDanglingReferences(originals []*desc.FileDescriptor, filtered map[string]*builder.FileBuilder, mode DanglingMode) error {
+	kept := make(map[string]builder.Builder)
+	for _, fileBuilder := range filtered {
+		collectBuilders(fileBuilder, kept)
+	}
+
+	inputs := make(map[string]struct{}, len(originals))
+	for _, fd := range originals {
+		inputs[fd.GetName()] = struct{}{}
+	}
+
+	var references []danglingReference
+	for _, fd := range originals {
+		if _, ok := filtered[fd.GetName()]; !ok {
+			continue
+		}
+		references = append(references, findDanglingReferences(fd, inputs, kept)...)
+	}
+	if len(references) == 0 {
+		return nil
+	}
+
+	if mode == DanglingFail {
+		lines := make([]string, len(references))
+		for i, reference := range references {
+			lines[i] = reference.String()
+		}
+		return fmt.Errorf("Kept elements refer to removed types:\n%s", strings.Join(lines, "\n"))
+	}
+
+	for _, reference := range references {
+		removeBuilder(kept[reference.user.GetFullyQualifiedName()])
+	}
+	return nil
+}
+
+// collectBuilders adds the builder and all of its descendants to result, keyed
+// by their fully qualified name
+func collectBuilders(b builder.Builder, result map[string]builder.Builder) {
+	if _, isFile := b.(*builder.FileBuilder); !isFile {
+		result[builder.GetFullyQualifiedName(b)] = b
+	}
+	for _, child := range b.GetChildren() {
+		collectBuilders(child, result)
+	}
+}
+
+// removeBuilder unlinks the builder from its parent. The map entry of a map
+// field is removed with it, as well as a oneof which has no choices left,
+// since it would not be valid.
+func removeBuilder(b builder.Builder) {
+	parent := b.GetParent()
+	if field, ok := b.(*builder.FieldBuilder); ok {
+		if messageBuilder, ok := parent.(*builder.MessageBuilder); ok {
+			if entry := mapEntry(messageBuilder, field); entry != nil {
+				builder.Unlink(entry)
+			}
+		}
+	}
+	builder.Unlink(b)
+	if oneOf, ok := parent.(*builder.OneOfBuilder); ok && len(oneOf.GetChildren()) == 0 {
+		builder.Unlink(oneOf)
+	}
+}
+
+// findDanglingReferences returns all elements in the file which are still kept
+// and refer to a type that is not. Only types defined in one of the inputs can
+// have been removed.
+func findDanglingReferences(fd *desc.FileDescriptor, inputs map[string]struct{}, kept map[string]builder.Builder) []danglingReference {
+	var result []danglingReference
+	check := func(user desc.Descriptor, referenced ...desc.Descriptor) {
+		if _, ok := kept[user.GetFullyQualifiedName()]; !ok {
+			return
+		}
+		for _, r := range referenced {
+			if _, isInput := inputs[r.GetFile().GetName()]; !isInput {
+				continue
+			}
+			if _, ok := kept[r.GetFullyQualifiedName()]; !ok {
+				result = append(result, danglingReference{user: user, typeName: r.GetFullyQualifiedName()})
+				return
+			}
+		}
+	}
+
+	var checkMessage func(md *desc.MessageDescriptor)
+	checkMessage = func(md *desc.MessageDescriptor) {
+		for _, field := range md.GetFields() {
+			check(field, fieldTypes(field)...)
+		}
+		for _, ext := range md.GetNestedExtensions() {
+			check(ext, append(fieldTypes(ext), ext.GetOwner())...)
+		}
+		for _, nested := range md.GetNestedMessageTypes() {
+			if !nested.IsMapEntry() {
+				checkMessage(nested)
+			}
+		}
+	}
+
+	for _, md := range fd.GetMessageTypes() {
+		checkMessage(md)
+	}
+	for _, ext := range fd.GetExtensions() {
+		check(ext, append(fieldTypes(ext), ext.GetOwner())...)
+	}
+	for _, sd := range fd.GetServices() {
+		for _, method := range sd.GetMethods() {
+			check(method, method.GetInputType(), method.GetOutputType())
+		}
+	}
+	return result
+}
+
+// fieldTypes returns the message or enum types a field refers to. For a map
+// field these are the types of its value, rather than the map entry itself.
+func fieldTypes(field *desc.FieldDescriptor) []desc.Descriptor {
+	if field.IsMap() {
+		return fieldTypes(field.GetMapValueType())
+	}
+	if msgType := field.GetMessageType(); msgType != nil {
+		return []desc.Descriptor{msgType}
+	}
+	if enumType := field.GetEnumType(); enumType != nil {
+		return []desc.Descriptor{enumType}
+	}
+	return nil
+}
+
+// sourcePosition formats the location of the descriptor in its source file as
+// `file:line:column`. It falls back to just the file name if the descriptor
+// has no source info.
+func sourcePosition(d desc.Descriptor) string {
+	if loc := d.GetSourceInfo(); loc != nil && len(loc.GetSpan()) >= 2 {
+		return fmt.Sprintf("%s:%d:%d", d.GetFile().GetName(), loc.GetSpan()[0]+1, loc.GetSpan()[1]+1)
+	}
+	return d.GetFile().GetName()
+}
@@ -0,0 +1,199 @@
+package protofilter
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Workiva/go-datastructures/set"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/builder"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const referencesTestProto = `
+syntax = "proto2";
+package test;
+
+import "filter/filter.proto";
+
+message Hidden {
+    option (filter.message).exclude = "foo";
+}
+
+enum HiddenEnum {
+    option (filter.enum).exclude = "foo";
+    HIDDEN_DEFAULT = 0;
+}
+
+message Holder {
+    optional string name = 1;
+    optional Hidden hidden = 2;
+    optional HiddenEnum hidden_enum = 3;
+    map<string, Hidden> hidden_map = 4;
+    oneof choice {
+        Hidden hidden_choice = 5;
+    }
+    oneof other_choice {
+        Hidden other_hidden_choice = 6;
+        string other_name = 7;
+    }
+    extensions 100 to 200;
+}
+
+extend Holder {
+    optional Hidden hidden_ext = 100;
+}
+
+service Service {
+    rpc Visible(Holder) returns (Holder);
+    rpc Invisible(Hidden) returns (Holder);
+}
+`
+
+const referencesTestImportingProto = `
+syntax = "proto3";
+package test.other;
+
+import "test.proto";
+
+message Importer {
+    test.Hidden hidden = 1;
+    test.Holder holder = 2;
+}
+`
+
+// parseTestFiles is a test helper that parses the given in memory proto files.
+// Other files, like filter/filter.proto, are read from the root of the repository.
+func parseTestFiles(t *testing.T, files map[string]string, names ...string) []*desc.FileDescriptor {
+	parser := protoparse.Parser{
+		ImportPaths:           []string{"."},
+		IncludeSourceCodeInfo: true,
+		Accessor: func(filename string) (io.ReadCloser, error) {
+			if contents, ok := files[filename]; ok {
+				return ioutil.NopCloser(strings.NewReader(contents)), nil
+			}
+			return os.Open(filepath.Join("..", filename))
+		},
+	}
+	descs, err := parser.ParseFiles(names...)
+	require.NoError(t, err)
+	return descs
+}
+
+// filterTestFiles is a test helper that filters the descriptors for the terms
+// and returns the builders of the files that were kept
+func filterTestFiles(t *testing.T, descs []*desc.FileDescriptor, terms *set.Set) map[string]*builder.FileBuilder {
+	filtered := make(map[string]*builder.FileBuilder, len(descs))
+	for _, fd := range descs {
+		fileBuilder, err := builder.FromFile(fd)
+		require.NoError(t, err)
+		removed, err := filterFile(fileBuilder, newTestScope(terms))
+		require.NoError(t, err)
+		if !removed {
+			filtered[fd.GetName()] = fileBuilder
+		}
+	}
+	return filtered
+}
+
+// builderNames returns the sorted fully qualified names of the builder and all
+// its descendants
+func builderNames(b builder.Builder) []string {
+	all := make(map[string]builder.Builder)
+	collectBuilders(b, all)
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestResolveDanglingReferences(t *testing.T) {
+	files := map[string]string{"test.proto": referencesTestProto, "other.proto": referencesTestImportingProto}
+
+	t.Run("Should not return an error if nothing refers to a removed type", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "test.proto", "other.proto")
+		filtered := filterTestFiles(t, descs, set.New("bar"))
+		assert.NoError(t, resolveDanglingReferences(descs, filtered, DanglingFail))
+	})
+
+	t.Run("Should report every dangling reference in fail mode", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "test.proto", "other.proto")
+		filtered := filterTestFiles(t, descs, set.New("foo"))
+		err := resolveDanglingReferences(descs, filtered, DanglingFail)
+		if assert.Error(t, err) {
+			for _, user := range []string{
+				"test.proto:18:5: test.Holder.hidden refers to removed type test.Hidden",
+				"test.Holder.hidden_enum refers to removed type test.HiddenEnum",
+				"test.Holder.hidden_map",
+				"test.Holder.hidden_choice",
+				"test.Holder.other_hidden_choice",
+				"test.hidden_ext",
+				"test.Service.Invisible",
+				"other.proto:8:5: test.other.Importer.hidden refers to removed type test.Hidden",
+			} {
+				assert.Contains(t, err.Error(), user)
+			}
+			assert.NotContains(t, err.Error(), "test.Holder.name")
+			assert.NotContains(t, err.Error(), "test.Service.Visible")
+		}
+	})
+
+	t.Run("Should remove every dangling reference in cascade mode", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "test.proto", "other.proto")
+		filtered := filterTestFiles(t, descs, set.New("foo"))
+		if assert.NoError(t, resolveDanglingReferences(descs, filtered, DanglingCascade)) {
+			assert.Equal(t, []string{
+				"test.Holder",
+				"test.Holder.name",
+				"test.Holder.other_choice",
+				"test.Holder.other_name",
+				"test.Service",
+				"test.Service.Visible",
+			}, builderNames(filtered["test.proto"]))
+			assert.Equal(t, []string{
+				"test.other.Importer",
+				"test.other.Importer.holder",
+			}, builderNames(filtered["other.proto"]))
+
+			for _, fileBuilder := range filtered {
+				_, err := fileBuilder.Build()
+				assert.NoError(t, err)
+			}
+		}
+	})
+}
+
+func TestFilterMessageMapField(t *testing.T) {
+	files := map[string]string{"map.proto": `
+syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+message Holder {
+    map<string, string> hidden_map = 1 [(filter.field).exclude = "foo"];
+    map<string, string> visible_map = 2;
+}
+`}
+
+	t.Run("Should remove the map entry together with the map field", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "map.proto")
+		filtered := filterTestFiles(t, descs, set.New("foo"))
+		assert.Equal(t, []string{
+			"test.Holder",
+			"test.Holder.VisibleMapEntry",
+			"test.Holder.VisibleMapEntry.key",
+			"test.Holder.VisibleMapEntry.value",
+			"test.Holder.visible_map",
+		}, builderNames(filtered["map.proto"]))
+	})
+}
@@ -0,0 +1,135 @@
+package protofilter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/wdullaer/proto-filter/filter"
+)
+
+// TermDefinition is a term that a project declared, with what it stands for
+type TermDefinition struct {
+	Name        string
+	Description string
+}
+
+// Registry holds the terms a project declared, so terms which were not
+// declared (typically typos) can be rejected. Filter itself does not use it.
+type Registry struct {
+	definitions map[string]TermDefinition
+}
+
+// NewRegistry creates a Registry of the definitions. The names have to be
+// literal terms. If a term is declared more than once, its first description
+// is kept.
+func NewRegistry(definitions []TermDefinition) (*Registry, error) {
+	registry := &Registry{definitions: make(map[string]TermDefinition, len(definitions))}
+	for _, definition := range definitions {
+		if msg := checkTerm(definition.Name); len(msg) != 0 {
+			return nil, fmt.Errorf("Invalid declared term: %s", msg)
+		}
+		if pattern, _ := compileTerm(definition.Name); pattern.re != nil {
+			return nil, fmt.Errorf("Declared term %q is a pattern, only literal terms can be declared", definition.Name)
+		}
+		if existing, ok := registry.definitions[definition.Name]; ok && len(existing.Description) != 0 {
+			continue
+		}
+		registry.definitions[definition.Name] = definition
+	}
+	return registry, nil
+}
+
+// DeclaredTerms returns the terms declared with the `(filter.terms)` file
+// option in the files and all files they import
+func DeclaredTerms(descs []*desc.FileDescriptor) ([]TermDefinition, error) {
+	var result []TermDefinition
+	seen := make(map[string]struct{})
+	var visit func(fd *desc.FileDescriptor) error
+	visit = func(fd *desc.FileDescriptor) error {
+		if _, ok := seen[fd.GetName()]; ok {
+			return nil
+		}
+		seen[fd.GetName()] = struct{}{}
+		if opts := fd.GetFileOptions(); opts != nil {
+			extVal, err := proto.GetExtension(opts, filter.E_Terms)
+			if err == nil {
+				for _, term := range extVal.(*filter.TermRegistry).GetTerm() {
+					result = append(result, TermDefinition{Name: term.GetName(), Description: term.GetDescription()})
+				}
+			} else if err != proto.ErrMissingExtension {
+				return fmt.Errorf("%s: %v", fd.GetName(), err)
+			}
+		}
+		for _, dep := range fd.GetDependencies() {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, fd := range descs {
+		if err := visit(fd); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Len returns the number of declared terms
+func (r *Registry) Len() int {
+	return len(r.definitions)
+}
+
+// Definitions returns the declared terms, sorted by name
+func (r *Registry) Definitions() []TermDefinition {
+	result := make([]TermDefinition, 0, len(r.definitions))
+	for _, definition := range r.definitions {
+		result = append(result, definition)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// ValidateTerm returns an error if the term is not declared. A glob or regular
+// expression has to match at least one declared term.
+func (r *Registry) ValidateTerm(term string) error {
+	pattern, err := compileTerm(term)
+	if err != nil {
+		return err
+	}
+	if pattern.re == nil {
+		if _, ok := r.definitions[term]; !ok {
+			return fmt.Errorf("Term %q is not declared", term)
+		}
+		return nil
+	}
+	for name := range r.definitions {
+		if pattern.re.MatchString(name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Term %q does not match any declared term", term)
+}
+
+// Check returns an error for every term used in an annotation in the files
+// which is not declared. Terms that are invalid patterns are left to Lint.
+func (r *Registry) Check(descs []*desc.FileDescriptor) ([]Problem, error) {
+	return checkAnnotations(descs, func(element Element, annotation *filter.ValueFilter, report reportFunc) {
+		terms := append(append([]string(nil), annotation.GetInclude()...), annotation.GetExclude()...)
+		if expr, err := parseExpression(annotation.GetExpression()); annotation.Expression != nil && err == nil {
+			terms = append(terms, expressionTerms(expr)...)
+		}
+		seen := make(map[string]struct{})
+		for _, term := range terms {
+			if _, ok := seen[term]; ok || ValidateTerm(term) != nil {
+				continue
+			}
+			seen[term] = struct{}{}
+			if err := r.ValidateTerm(term); err != nil {
+				report(SeverityError, "%s", err)
+			}
+		}
+	})
+}
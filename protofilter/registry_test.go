@@ -0,0 +1,127 @@
+package protofilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const registryTermsProto = `syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+option (filter.terms) = {
+    term: { name: "NA" description: "North America" }
+    term: { name: "partner.acme" }
+};
+`
+
+const registryTestProto = `syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+import "terms.proto";
+
+option (filter.terms).term = { name: "EU" description: "Europe" };
+
+message Message {
+    string na = 1 [(filter.field).include = "NA"];
+    string typo = 2 [(filter.field).include = "NA ", (filter.field).exclude = "NAA"];
+    string partner = 3 [(filter.field).exclude = "partner.*"];
+    string other = 4 [(filter.field).exclude = "other.*"];
+    string expression = 5 [(filter.field).expression = "EU && !JP"];
+}
+`
+
+func TestDeclaredTerms(t *testing.T) {
+	t.Run("Should collect the declared terms of the files and their imports", func(t *testing.T) {
+		descs := parseTestFiles(t, map[string]string{"terms.proto": registryTermsProto, "registry.proto": registryTestProto}, "registry.proto")
+
+		definitions, err := DeclaredTerms(descs)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []TermDefinition{
+				{Name: "EU", Description: "Europe"},
+				{Name: "NA", Description: "North America"},
+				{Name: "partner.acme"},
+			}, definitions)
+		}
+	})
+}
+
+func TestNewRegistry(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  []TermDefinition
+		output string
+	}{
+		{name: "Should reject an empty term", input: []TermDefinition{{Name: ""}}, output: "Invalid declared term: Empty term"},
+		{name: "Should reject whitespace", input: []TermDefinition{{Name: "NA "}}, output: `Invalid declared term: Term "NA " has leading or trailing whitespace`},
+		{name: "Should reject a pattern", input: []TermDefinition{{Name: "partner.*"}}, output: `Declared term "partner.*" is a pattern, only literal terms can be declared`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewRegistry(tc.input)
+			assert.EqualError(t, err, tc.output)
+		})
+	}
+
+	t.Run("Should keep the first description of a term", func(t *testing.T) {
+		registry, err := NewRegistry([]TermDefinition{{Name: "NA", Description: "North America"}, {Name: "EU"}, {Name: "NA", Description: "Other"}})
+		if assert.NoError(t, err) {
+			assert.Equal(t, []TermDefinition{{Name: "EU"}, {Name: "NA", Description: "North America"}}, registry.Definitions())
+		}
+	})
+}
+
+func TestRegistryValidateTerm(t *testing.T) {
+	registry, err := NewRegistry([]TermDefinition{{Name: "NA"}, {Name: "partner.acme"}})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{name: "Should accept a declared term", input: "NA"},
+		{name: "Should accept a pattern matching a declared term", input: "partner.*"},
+		{name: "Should accept a regular expression matching a declared term", input: "/^N/"},
+		{name: "Should reject an undeclared term", input: "NAA", output: `Term "NAA" is not declared`},
+		{name: "Should reject a pattern that matches nothing", input: "other.*", output: `Term "other.*" does not match any declared term`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := registry.ValidateTerm(tc.input)
+			if len(tc.output) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.output)
+			}
+		})
+	}
+}
+
+func TestRegistryCheck(t *testing.T) {
+	t.Run("Should report the annotations using undeclared terms", func(t *testing.T) {
+		descs := parseTestFiles(t, map[string]string{"terms.proto": registryTermsProto, "registry.proto": registryTestProto}, "registry.proto")
+		definitions, err := DeclaredTerms(descs)
+		require.NoError(t, err)
+		registry, err := NewRegistry(definitions)
+		require.NoError(t, err)
+
+		problems, err := registry.Check(descs)
+		require.NoError(t, err)
+
+		lines := make([]string, len(problems))
+		for i, problem := range problems {
+			lines[i] = problem.String()
+		}
+		assert.Equal(t, []string{
+			`registry.proto:11:5: error: test.Message.typo: Term "NA " is not declared`,
+			`registry.proto:11:5: error: test.Message.typo: Term "NAA" is not declared`,
+			`registry.proto:13:5: error: test.Message.other: Term "other.*" does not match any declared term`,
+			`registry.proto:14:5: error: test.Message.expression: Term "JP" is not declared`,
+		}, lines)
+	})
+}
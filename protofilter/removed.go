@@ -0,0 +1,206 @@
+package protofilter
+
+import (
+	"github.com/jhump/protoreflect/desc"
+)
+
+// Removal describes an element of the input which is not part of the result
+type Removal struct {
+	Kind Kind
+	// Name is the fully qualified name of the element (the file name for a file)
+	Name string
+	// File is the name of the file the element was defined in
+	File string
+	// Line and Column are the 1-based position of the element in its file, or
+	// 0 if the descriptor has no source info
+	Line   int
+	Column int
+	// Reason tells why the element was removed. Elements which were removed
+	// together with an enclosing element share its reason.
+	Reason Reason
+	// RemovedWith is the fully qualified name of the enclosing element the
+	// element was removed with, empty if it was removed by itself
+	RemovedWith string
+	// DecidedBy is the fully qualified name of the enclosing element whose
+	// decision the element inherited, while that element was kept for other
+	// children. It is empty if the rules decided about the element itself.
+	DecidedBy string
+}
+
+// The reasons for removals which were not decided by a rule
+const (
+	reasonShake    = "shake"
+	reasonPolicy   = "deny policy"
+	reasonDangling = "dangling reference"
+)
+
+// findRemovals compares the filtered files with the descriptors they were
+// created from and returns every element that was removed, in the order of
+// the input. decisions holds the explanation of every element that was
+// judged, keyed by name, and shaken the types removed by shakeTypes.
+//
+// The map entries of map fields are not reported: they are an implementation
+// detail of the map field they belong to.
+func findRemovals(originals []*desc.FileDescriptor, files []*desc.FileDescriptor, decisions map[string]Explanation, shaken map[string]struct{}, policy Policy) []Removal {
+	keptFiles := make(map[string]struct{}, len(files))
+	kept := make(map[string]struct{})
+	for _, fd := range files {
+		keptFiles[fd.GetName()] = struct{}{}
+		for _, child := range childDescriptors(fd) {
+			collectDescriptors(child, kept)
+		}
+	}
+
+	var result []Removal
+	var visit func(d desc.Descriptor, parent *Removal)
+	visit = func(d desc.Descriptor, parent *Removal) {
+		var isKept bool
+		if _, isFile := d.(*desc.FileDescriptor); isFile {
+			_, isKept = keptFiles[d.GetName()]
+		} else {
+			_, isKept = kept[d.GetFullyQualifiedName()]
+		}
+		if isKept {
+			for _, child := range childDescriptors(d) {
+				visit(child, nil)
+			}
+			return
+		}
+
+		removal := Removal{
+			Kind: kindOf(d),
+			Name: d.GetFullyQualifiedName(),
+			File: d.GetFile().GetName(),
+		}
+		removal.Line, removal.Column = position(d)
+		if parent != nil {
+			removal.Reason = parent.Reason
+			removal.RemovedWith = parent.RemovedWith
+			if len(removal.RemovedWith) == 0 {
+				removal.RemovedWith = parent.Name
+			}
+		} else {
+			removal.Reason, removal.DecidedBy = removalReason(d, decisions, shaken, policy)
+		}
+		result = append(result, removal)
+		for _, child := range childDescriptors(d) {
+			visit(child, &removal)
+		}
+	}
+	for _, fd := range originals {
+		visit(fd, nil)
+	}
+	return result
+}
+
+// removalReason returns why the element d was removed by itself. An element
+// that inherited a Drop from an enclosing element which was kept as a
+// container is explained by the decision of that element, whose name is
+// returned as well.
+func removalReason(d desc.Descriptor, decisions map[string]Explanation, shaken map[string]struct{}, policy Policy) (Reason, string) {
+	explanation, judged := decisions[d.GetFullyQualifiedName()]
+	if judged && explanation.Effective == Drop {
+		decidedBy := ""
+		for ancestor := parentOf(d); explanation.Inherited && ancestor != nil; ancestor = parentOf(ancestor) {
+			explanation, decidedBy = decisions[ancestor.GetFullyQualifiedName()], ancestor.GetFullyQualifiedName()
+		}
+		return explanation.Reason, decidedBy
+	}
+	if _, ok := shaken[d.GetFullyQualifiedName()]; ok {
+		return Reason{Rule: reasonShake}, ""
+	}
+	if judged && explanation.Effective == Abstain && policy == PolicyDeny {
+		return Reason{Rule: reasonPolicy}, ""
+	}
+	// Kept elements are only removed afterwards if they refer to a removed type
+	// (or are a oneof of which all choices did)
+	return Reason{Rule: reasonDangling}, ""
+}
+
+// position returns the 1-based line and column of the descriptor in its file,
+// or 0, 0 if it has no source info
+func position(d desc.Descriptor) (int, int) {
+	if loc := d.GetSourceInfo(); loc != nil && len(loc.GetSpan()) >= 2 {
+		return int(loc.GetSpan()[0]) + 1, int(loc.GetSpan()[1]) + 1
+	}
+	return 0, 0
+}
+
+// childDescriptors returns the children of d in the same structure as the
+// builders: the choices of a oneof are children of the oneof rather than of
+// the message. Map entries are left out, since they are part of their field.
+func childDescriptors(d desc.Descriptor) []desc.Descriptor {
+	var result []desc.Descriptor
+	switch d := d.(type) {
+	case *desc.FileDescriptor:
+		return fileChildren(d)
+	case *desc.MessageDescriptor:
+		return messageChildren(d)
+	case *desc.OneOfDescriptor:
+		for _, field := range d.GetChoices() {
+			result = append(result, field)
+		}
+	case *desc.EnumDescriptor:
+		for _, value := range d.GetValues() {
+			result = append(result, value)
+		}
+	case *desc.ServiceDescriptor:
+		for _, method := range d.GetMethods() {
+			result = append(result, method)
+		}
+	}
+	return result
+}
+
+// fileChildren returns the top level elements of the file
+func fileChildren(fd *desc.FileDescriptor) []desc.Descriptor {
+	var result []desc.Descriptor
+	for _, md := range fd.GetMessageTypes() {
+		result = append(result, md)
+	}
+	for _, ed := range fd.GetEnumTypes() {
+		result = append(result, ed)
+	}
+	for _, ext := range fd.GetExtensions() {
+		result = append(result, ext)
+	}
+	for _, sd := range fd.GetServices() {
+		result = append(result, sd)
+	}
+	return result
+}
+
+// messageChildren returns the children of the message, without the choices of
+// its oneofs and its map entries
+func messageChildren(md *desc.MessageDescriptor) []desc.Descriptor {
+	var result []desc.Descriptor
+	for _, field := range md.GetFields() {
+		if field.GetOneOf() == nil {
+			result = append(result, field)
+		}
+	}
+	for _, oneOf := range md.GetOneOfs() {
+		result = append(result, oneOf)
+	}
+	for _, nested := range md.GetNestedMessageTypes() {
+		if !nested.IsMapEntry() {
+			result = append(result, nested)
+		}
+	}
+	for _, ed := range md.GetNestedEnumTypes() {
+		result = append(result, ed)
+	}
+	for _, ext := range md.GetNestedExtensions() {
+		result = append(result, ext)
+	}
+	return result
+}
+
+// collectDescriptors adds the fully qualified names of d and all of its
+// descendants to result
+func collectDescriptors(d desc.Descriptor, result map[string]struct{}) {
+	result[d.GetFullyQualifiedName()] = struct{}{}
+	for _, child := range childDescriptors(d) {
+		collectDescriptors(child, result)
+	}
+}
@@ -0,0 +1,160 @@
+package protofilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const removedTestProto = `syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+message Message {
+    string name = 1;
+    string secret = 2 [(filter.field).exclude = "foo"];
+    map<string, Hidden> hidden = 3;
+    Unused unused = 4 [(filter.field).exclude = "bar"];
+}
+
+message Hidden {
+    option (filter.message).exclude = "foo";
+    string value = 1;
+}
+
+message Unused {
+    string value = 1;
+}
+
+service Service {
+    rpc Method(Message) returns (Message);
+}
+`
+
+const removedTestContainerProto = `syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+message Container {
+    option (filter.message).exclude = "foo";
+    string name = 1;
+    string value = 2 [(filter.field).include = "foo"];
+}
+`
+
+func TestFindRemovals(t *testing.T) {
+	files := map[string]string{"removed.proto": removedTestProto}
+
+	t.Run("Should report every removed element with its position and reason", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "removed.proto")
+
+		result, err := Filter(descs, Options{Terms: []string{"foo", "bar"}, Dangling: DanglingCascade, Shake: true})
+		require.NoError(t, err)
+
+		assert.Equal(t, []Removal{
+			{
+				Kind:   KindField,
+				Name:   "test.Message.secret",
+				File:   "removed.proto",
+				Line:   8,
+				Column: 5,
+				Reason: Reason{Rule: "annotation exclude", Terms: []string{"foo"}},
+			},
+			{
+				Kind:   KindField,
+				Name:   "test.Message.hidden",
+				File:   "removed.proto",
+				Line:   9,
+				Column: 5,
+				Reason: Reason{Rule: reasonDangling},
+			},
+			{
+				Kind:   KindField,
+				Name:   "test.Message.unused",
+				File:   "removed.proto",
+				Line:   10,
+				Column: 5,
+				Reason: Reason{Rule: "annotation exclude", Terms: []string{"bar"}},
+			},
+			{
+				Kind:   KindMessage,
+				Name:   "test.Hidden",
+				File:   "removed.proto",
+				Line:   13,
+				Column: 1,
+				Reason: Reason{Rule: "annotation exclude", Terms: []string{"foo"}},
+			},
+			{
+				Kind:        KindField,
+				Name:        "test.Hidden.value",
+				File:        "removed.proto",
+				Line:        15,
+				Column:      5,
+				Reason:      Reason{Rule: "annotation exclude", Terms: []string{"foo"}},
+				RemovedWith: "test.Hidden",
+			},
+			{
+				Kind:   KindMessage,
+				Name:   "test.Unused",
+				File:   "removed.proto",
+				Line:   18,
+				Column: 1,
+				Reason: Reason{Rule: reasonShake},
+			},
+			{
+				Kind:        KindField,
+				Name:        "test.Unused.value",
+				File:        "removed.proto",
+				Line:        19,
+				Column:      5,
+				Reason:      Reason{Rule: reasonShake},
+				RemovedWith: "test.Unused",
+			},
+		}, result.Removed)
+	})
+
+	t.Run("Should report the elements removed by the deny policy", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "removed.proto")
+
+		result, err := Filter(descs, Options{Terms: []string{"baz"}, Policy: PolicyDeny})
+		require.NoError(t, err)
+
+		names := make(map[string]Reason)
+		for _, removal := range result.Removed {
+			names[removal.Name] = removal.Reason
+		}
+		assert.Equal(t, Reason{Rule: reasonPolicy}, names["test.Message"])
+		assert.Equal(t, Reason{Rule: reasonPolicy}, names["test.Service"])
+		assert.NotContains(t, names, "removed.proto", "Should not remove the file under the deny policy")
+	})
+
+	t.Run("Should report the decision of a removed element kept as a container", func(t *testing.T) {
+		descs := parseTestFiles(t, map[string]string{"container.proto": removedTestContainerProto}, "container.proto")
+
+		result, err := Filter(descs, Options{Terms: []string{"foo"}})
+		require.NoError(t, err)
+
+		assert.Equal(t, []Removal{
+			{
+				Kind:      KindField,
+				Name:      "test.Container.name",
+				File:      "container.proto",
+				Line:      8,
+				Column:    5,
+				Reason:    Reason{Rule: "annotation exclude", Terms: []string{"foo"}},
+				DecidedBy: "test.Container",
+			},
+		}, result.Removed)
+	})
+
+	t.Run("Should not report anything if nothing was removed", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "removed.proto")
+
+		result, err := Filter(descs, Options{Terms: []string{"baz"}})
+		require.NoError(t, err)
+		assert.Empty(t, result.Removed)
+	})
+}
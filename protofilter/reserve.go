@@ -0,0 +1,83 @@
+package protofilter
+
+import (
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/builder"
+)
+
+// reserveRemoved adds a reserved number for every field and enum value that
+// was removed from a kept message or enum, so the filtered schema can not
+// accidentally reuse it. If names is true, the names of the removed elements
+// are reserved as well.
+//
+// The removed elements are found by comparing the filtered builders with the
+// descriptors they were created from, which makes this independent of the
+// reason why an element was removed.
+func reserveRemoved(originals []*desc.FileDescriptor, filtered map[string]*builder.FileBuilder, names bool) {
+	kept := make(map[string]builder.Builder)
+	for _, fileBuilder := range filtered {
+		collectBuilders(fileBuilder, kept)
+	}
+
+	var reserveMessage func(md *desc.MessageDescriptor)
+	reserveMessage = func(md *desc.MessageDescriptor) {
+		messageBuilder, ok := kept[md.GetFullyQualifiedName()].(*builder.MessageBuilder)
+		if !ok {
+			return
+		}
+		for _, field := range md.GetFields() {
+			if _, ok := kept[field.GetFullyQualifiedName()]; ok {
+				continue
+			}
+			messageBuilder.AddReservedRange(field.GetNumber(), field.GetNumber())
+			if names {
+				messageBuilder.AddReservedName(field.GetName())
+			}
+		}
+		for _, nested := range md.GetNestedMessageTypes() {
+			reserveMessage(nested)
+		}
+		for _, ed := range md.GetNestedEnumTypes() {
+			reserveEnum(ed, kept, names)
+		}
+	}
+
+	for _, fd := range originals {
+		if _, ok := filtered[fd.GetName()]; !ok {
+			continue
+		}
+		for _, md := range fd.GetMessageTypes() {
+			reserveMessage(md)
+		}
+		for _, ed := range fd.GetEnumTypes() {
+			reserveEnum(ed, kept, names)
+		}
+	}
+}
+
+// reserveEnum adds reserved numbers (and optionally names) to the builder of
+// the enum for its removed values. A number is only reserved if no kept value
+// uses it anymore, since enums can contain aliases.
+func reserveEnum(ed *desc.EnumDescriptor, kept map[string]builder.Builder, names bool) {
+	enumBuilder, ok := kept[ed.GetFullyQualifiedName()].(*builder.EnumBuilder)
+	if !ok {
+		return
+	}
+
+	used := make(map[int32]struct{})
+	for _, child := range enumBuilder.GetChildren() {
+		used[child.(*builder.EnumValueBuilder).GetNumber()] = struct{}{}
+	}
+	for _, value := range ed.GetValues() {
+		if _, ok := kept[value.GetFullyQualifiedName()]; ok {
+			continue
+		}
+		if _, ok := used[value.GetNumber()]; !ok {
+			enumBuilder.AddReservedRange(value.GetNumber(), value.GetNumber())
+			used[value.GetNumber()] = struct{}{}
+		}
+		if names {
+			enumBuilder.AddReservedName(value.GetName())
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package protofilter
+
+import (
+	"testing"
+
+	"github.com/Workiva/go-datastructures/set"
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/stretchr/testify/assert"
+)
+
+const reserveTestProto = `
+syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+message Message {
+    string kept = 1;
+    string hidden = 2 [(filter.field).exclude = "foo"];
+    oneof choice {
+        string hidden_choice = 3 [(filter.field).exclude = "foo"];
+        string kept_choice = 4;
+    }
+    oneof hidden_oneof {
+        option (filter.one_of).exclude = "foo";
+        string first = 5;
+        string second = 6;
+    }
+
+    message Hidden {
+        option (filter.message).exclude = "foo";
+        string field = 1;
+    }
+}
+
+enum Enum {
+    option allow_alias = true;
+    ENUM_DEFAULT = 0;
+    ENUM_HIDDEN = 1 [(filter.enum_value).exclude = "foo"];
+    ENUM_ALIASED = 2 [(filter.enum_value).exclude = "foo"];
+    ENUM_ALIAS = 2;
+}
+`
+
+func TestReserveRemoved(t *testing.T) {
+	files := map[string]string{"reserve.proto": reserveTestProto}
+
+	t.Run("Should reserve the numbers of removed fields and enum values", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "reserve.proto")
+		filtered := filterTestFiles(t, descs, set.New("foo"))
+		reserveRemoved(descs, filtered, false)
+
+		fd, err := filtered["reserve.proto"].Build()
+		if assert.NoError(t, err) {
+			message := fd.FindMessage("test.Message").AsDescriptorProto()
+			assert.ElementsMatch(t, []*dpb.DescriptorProto_ReservedRange{
+				{Start: proto.Int32(2), End: proto.Int32(3)},
+				{Start: proto.Int32(3), End: proto.Int32(4)},
+				{Start: proto.Int32(5), End: proto.Int32(6)},
+				{Start: proto.Int32(6), End: proto.Int32(7)},
+			}, message.GetReservedRange())
+			assert.Empty(t, message.GetReservedName())
+
+			enum := fd.FindEnum("test.Enum").AsEnumDescriptorProto()
+			assert.ElementsMatch(t, []*dpb.EnumDescriptorProto_EnumReservedRange{
+				{Start: proto.Int32(1), End: proto.Int32(1)},
+			}, enum.GetReservedRange(), "Expected the number of an alias that is still used not to be reserved")
+			assert.Empty(t, enum.GetReservedName())
+		}
+	})
+
+	t.Run("Should reserve the names of removed fields and enum values if requested", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "reserve.proto")
+		filtered := filterTestFiles(t, descs, set.New("foo"))
+		reserveRemoved(descs, filtered, true)
+
+		fd, err := filtered["reserve.proto"].Build()
+		if assert.NoError(t, err) {
+			assert.ElementsMatch(t,
+				[]string{"hidden", "hidden_choice", "first", "second"},
+				fd.FindMessage("test.Message").AsDescriptorProto().GetReservedName())
+			assert.ElementsMatch(t,
+				[]string{"ENUM_HIDDEN", "ENUM_ALIASED"},
+				fd.FindEnum("test.Enum").AsEnumDescriptorProto().GetReservedName())
+		}
+	})
+
+	t.Run("Should not reserve anything if nothing was removed", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "reserve.proto")
+		filtered := filterTestFiles(t, descs, set.New("bar"))
+		reserveRemoved(descs, filtered, true)
+
+		fd, err := filtered["reserve.proto"].Build()
+		if assert.NoError(t, err) {
+			assert.Empty(t, fd.FindMessage("test.Message").AsDescriptorProto().GetReservedRange())
+			assert.Empty(t, fd.FindEnum("test.Enum").AsEnumDescriptorProto().GetReservedRange())
+		}
+	})
+}
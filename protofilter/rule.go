@@ -0,0 +1,285 @@
+package protofilter
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/Workiva/go-datastructures/set"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/wdullaer/proto-filter/filter"
+)
+
+// Decision is the outcome of applying a Rule to an element
+type Decision int
+
+const (
+	// Abstain means the rule has no opinion about the element, which then
+	// inherits the decision of its parent
+	Abstain Decision = iota
+	// Keep means the element is explicitly kept
+	Keep
+	// Drop means the element should be removed. Its children inherit the
+	// decision, but can opt back in, in which case the element is kept to
+	// hold them.
+	Drop
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Abstain:
+		return "abstain"
+	case Keep:
+		return "keep"
+	case Drop:
+		return "drop"
+	}
+	return fmt.Sprintf("Decision(%d)", int(d))
+}
+
+// Kind is the type of element a rule is applied to
+type Kind int
+
+// The kinds of elements that rules are applied to
+const (
+	KindFile Kind = iota
+	KindMessage
+	KindField
+	KindOneOf
+	KindEnum
+	KindEnumValue
+	KindService
+	KindMethod
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindFile:
+		return "file"
+	case KindMessage:
+		return "message"
+	case KindField:
+		return "field"
+	case KindOneOf:
+		return "oneof"
+	case KindEnum:
+		return "enum"
+	case KindEnumValue:
+		return "enum value"
+	case KindService:
+		return "service"
+	case KindMethod:
+		return "method"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// kindOf returns the Kind of the element described by d
+func kindOf(d desc.Descriptor) Kind {
+	switch d.(type) {
+	case *desc.MessageDescriptor:
+		return KindMessage
+	case *desc.FieldDescriptor:
+		return KindField
+	case *desc.OneOfDescriptor:
+		return KindOneOf
+	case *desc.EnumDescriptor:
+		return KindEnum
+	case *desc.EnumValueDescriptor:
+		return KindEnumValue
+	case *desc.ServiceDescriptor:
+		return KindService
+	case *desc.MethodDescriptor:
+		return KindMethod
+	}
+	return KindFile
+}
+
+// Element is an element of a proto file that a Rule decides about
+type Element struct {
+	// Descriptor describes the element. Its children have not been filtered
+	// yet when the rules are applied.
+	Descriptor desc.Descriptor
+	Kind       Kind
+	// Name is the fully qualified name of the element (the file name for a file)
+	Name string
+	// Parents are the descriptors enclosing the element, starting at its file
+	Parents []desc.Descriptor
+}
+
+// Rule decides whether an element is kept or removed. Elements for which
+// every rule abstains inherit the decision of their parent, or fall back to
+// the Policy if none of their ancestors has a decision either.
+//
+// Rules are applied from the top of the tree down, to the children of a
+// dropped element as well. Decide can be called from multiple goroutines at
+// the same time.
+type Rule interface {
+	Decide(element Element) (Decision, error)
+}
+
+// RuleFunc is an adapter to use an ordinary function as a Rule
+type RuleFunc func(element Element) (Decision, error)
+
+// Decide calls f(element)
+func (f RuleFunc) Decide(element Element) (Decision, error) {
+	return f(element)
+}
+
+// Reason describes why a rule reached its decision
+type Reason struct {
+	// Rule is the name of the rule that decided, empty if every rule abstained
+	Rule string
+	// Terms are the annotation terms that matched, if any
+	Terms []string
+}
+
+// explainer is implemented by rules that can tell why they reached a decision
+type explainer interface {
+	decideWithReason(element Element) (Decision, Reason, error)
+}
+
+// decideWithReason applies the rule to the element. Rules that cannot explain
+// themselves are described as "custom rule".
+func decideWithReason(rule Rule, element Element) (Decision, Reason, error) {
+	if e, ok := rule.(explainer); ok {
+		return e.decideWithReason(element)
+	}
+	result, err := rule.Decide(element)
+	if err != nil || result == Abstain {
+		return result, Reason{}, err
+	}
+	return result, Reason{Rule: "custom rule"}, nil
+}
+
+// namedRule is a Rule with a name for its explanations
+type namedRule struct {
+	name string
+	rule Rule
+}
+
+// NamedRule returns a Rule which decides like rule, and is reported under the
+// given name when Options.Explain is set
+func NamedRule(name string, rule Rule) Rule {
+	return &namedRule{name: name, rule: rule}
+}
+
+func (r *namedRule) Decide(element Element) (Decision, error) {
+	return r.rule.Decide(element)
+}
+
+func (r *namedRule) decideWithReason(element Element) (Decision, Reason, error) {
+	result, reason, err := decideWithReason(r.rule, element)
+	if err != nil || result == Abstain {
+		return result, reason, err
+	}
+	if len(reason.Rule) == 0 || reason.Rule == "custom rule" {
+		reason.Rule = r.name
+	} else {
+		reason.Rule = r.name + ": " + reason.Rule
+	}
+	return result, reason, nil
+}
+
+// chain is a Rule that asks its rules in order
+type chain []Rule
+
+// Chain returns a Rule which applies the rules in order. The first decision
+// that is not Abstain wins.
+func Chain(rules ...Rule) Rule {
+	return chain(rules)
+}
+
+func (c chain) Decide(element Element) (Decision, error) {
+	for _, rule := range c {
+		if result, err := rule.Decide(element); err != nil || result != Abstain {
+			return result, err
+		}
+	}
+	return Abstain, nil
+}
+
+func (c chain) decideWithReason(element Element) (Decision, Reason, error) {
+	for _, rule := range c {
+		if result, reason, err := decideWithReason(rule, element); err != nil || result != Abstain {
+			return result, reason, err
+		}
+	}
+	return Abstain, Reason{}, nil
+}
+
+// annotationRule is the Rule that evaluates the filter annotations
+type annotationRule struct {
+	terms *termSet
+}
+
+// NewAnnotationRule returns the Rule which applies the filter annotations
+// (filter/filter.proto) of the elements to the terms. It is the rule Filter
+// uses for Options.Terms. The rule can be shared by concurrent Filter calls.
+func NewAnnotationRule(terms []string) (Rule, error) {
+	values := make([]interface{}, len(terms))
+	for i, term := range terms {
+		values[i] = term
+	}
+	termSet, err := newTermSet(set.New(values...))
+	if err != nil {
+		return nil, err
+	}
+	return &annotationRule{terms: termSet}, nil
+}
+
+func (r *annotationRule) Decide(element Element) (Decision, error) {
+	annotation, err := annotationOf(element.Descriptor)
+	if err != nil || annotation == nil {
+		return Abstain, err
+	}
+	return evaluateFilter(annotation, r.terms)
+}
+
+func (r *annotationRule) decideWithReason(element Element) (Decision, Reason, error) {
+	annotation, err := annotationOf(element.Descriptor)
+	if err != nil || annotation == nil {
+		return Abstain, Reason{}, err
+	}
+	result, reason, err := explainFilter(annotation, r.terms)
+	if len(reason.Rule) != 0 {
+		reason.Rule = "annotation " + reason.Rule
+	}
+	return result, reason, err
+}
+
+// annotationOf extracts the filter annotation from the options of the
+// element. It returns nil if the element has no annotation.
+func annotationOf(d desc.Descriptor) (*filter.ValueFilter, error) {
+	var options proto.Message
+	var ext *proto.ExtensionDesc
+	switch d := d.(type) {
+	case *desc.FileDescriptor:
+		options, ext = d.GetFileOptions(), filter.E_File
+	case *desc.MessageDescriptor:
+		options, ext = d.GetMessageOptions(), filter.E_Message
+	case *desc.FieldDescriptor:
+		options, ext = d.GetFieldOptions(), filter.E_Field
+	case *desc.OneOfDescriptor:
+		options, ext = d.GetOneOfOptions(), filter.E_OneOf
+	case *desc.EnumDescriptor:
+		options, ext = d.GetEnumOptions(), filter.E_Enum
+	case *desc.EnumValueDescriptor:
+		options, ext = d.GetEnumValueOptions(), filter.E_EnumValue
+	case *desc.ServiceDescriptor:
+		options, ext = d.GetServiceOptions(), filter.E_Service
+	case *desc.MethodDescriptor:
+		options, ext = d.GetMethodOptions(), filter.E_Method
+	}
+	if options == nil || reflect.ValueOf(options).IsNil() {
+		return nil, nil
+	}
+
+	extVal, err := proto.GetExtension(options, ext)
+	if err == proto.ErrMissingExtension {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return extVal.(*filter.ValueFilter), nil
+}
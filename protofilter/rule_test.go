@@ -0,0 +1,209 @@
+package protofilter
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ruleTestProto = `
+syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+message Message {
+    string name = 1;
+    string internal_id = 2;
+    string old = 3 [deprecated = true];
+    string annotated = 4 [(filter.field).exclude = "foo"];
+    oneof choice {
+        string first = 5;
+    }
+    string beta = 6 [(filter.field).expression = "foo && !bar"];
+    string partner = 7 [(filter.field).include = "partner.*"];
+}
+
+enum Enum {
+    ENUM_DEFAULT = 0;
+}
+
+service Service {
+    rpc Method(Message) returns (Message);
+}
+`
+
+// constantRule is a test helper which returns a rule that always decides the same
+func constantRule(result Decision) Rule {
+	return RuleFunc(func(Element) (Decision, error) {
+		return result, nil
+	})
+}
+
+func TestChain(t *testing.T) {
+	cases := []struct {
+		name   string
+		rules  []Rule
+		output Decision
+	}{
+		{
+			name:   "Should abstain without rules",
+			rules:  nil,
+			output: Abstain,
+		},
+		{
+			name:   "Should abstain if all rules abstain",
+			rules:  []Rule{constantRule(Abstain), constantRule(Abstain)},
+			output: Abstain,
+		},
+		{
+			name:   "Should return the first decision",
+			rules:  []Rule{constantRule(Abstain), constantRule(Drop), constantRule(Keep)},
+			output: Drop,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result, err := Chain(tc.rules...).Decide(Element{}); assert.NoError(t, err) {
+				assert.Equal(t, tc.output, result)
+			}
+		})
+	}
+
+	t.Run("Should stop at the first error", func(t *testing.T) {
+		failing := RuleFunc(func(Element) (Decision, error) {
+			return Abstain, errors.New("failed")
+		})
+		_, err := Chain(failing, constantRule(Keep)).Decide(Element{})
+		assert.EqualError(t, err, "failed")
+	})
+}
+
+func TestFilterRules(t *testing.T) {
+	files := map[string]string{"rule.proto": ruleTestProto}
+
+	t.Run("Should pass the kind, name and parents of every element to the rules", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "rule.proto")
+		var mu sync.Mutex
+		elements := make(map[string]Element)
+		recorder := RuleFunc(func(element Element) (Decision, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			elements[element.Name] = element
+			return Abstain, nil
+		})
+
+		_, err := Filter(descs, Options{Rules: []Rule{recorder}})
+		require.NoError(t, err)
+
+		parentNames := func(parents []desc.Descriptor) []string {
+			names := make([]string, len(parents))
+			for i, parent := range parents {
+				names[i] = parent.GetFullyQualifiedName()
+			}
+			return names
+		}
+		for name, kind := range map[string]Kind{
+			"rule.proto":               KindFile,
+			"test.Message":             KindMessage,
+			"test.Message.name":        KindField,
+			"test.Message.choice":      KindOneOf,
+			"test.Enum":                KindEnum,
+			"test.Enum.ENUM_DEFAULT":   KindEnumValue,
+			"test.Service":             KindService,
+			"test.Service.Method":      KindMethod,
+			"test.Message.first":       KindField,
+			"test.Message.old":         KindField,
+			"test.Message.internal_id": KindField,
+		} {
+			if assert.Contains(t, elements, name) {
+				assert.Equal(t, kind, elements[name].Kind, name)
+				assert.Equal(t, name, elements[name].Descriptor.GetFullyQualifiedName())
+			}
+		}
+		assert.Empty(t, elements["rule.proto"].Parents)
+		assert.Equal(t, []string{"rule.proto", "test.Message"}, parentNames(elements["test.Message.name"].Parents))
+		assert.Equal(t, []string{"rule.proto", "test.Message", "test.Message.choice"}, parentNames(elements["test.Message.first"].Parents))
+		assert.Equal(t, []string{"rule.proto", "test.Service"}, parentNames(elements["test.Service.Method"].Parents))
+	})
+
+	t.Run("Should remove the elements dropped by a custom rule", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "rule.proto")
+		deprecated := RuleFunc(func(element Element) (Decision, error) {
+			if field, ok := element.Descriptor.(*desc.FieldDescriptor); ok && field.GetFieldOptions().GetDeprecated() {
+				return Drop, nil
+			}
+			return Abstain, nil
+		})
+		internal := RuleFunc(func(element Element) (Decision, error) {
+			if element.Kind == KindField && strings.HasPrefix(element.Descriptor.GetName(), "internal_") {
+				return Drop, nil
+			}
+			return Abstain, nil
+		})
+
+		result, err := Filter(descs, Options{Terms: []string{"foo"}, Rules: []Rule{deprecated, internal}})
+		if assert.NoError(t, err) {
+			message := result.Files[0].FindMessage("test.Message")
+			assert.NotNil(t, message.FindFieldByName("name"))
+			assert.Nil(t, message.FindFieldByName("internal_id"))
+			assert.Nil(t, message.FindFieldByName("old"))
+			assert.Nil(t, message.FindFieldByName("annotated"))
+		}
+	})
+
+	t.Run("Should apply the rules before the annotations", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "rule.proto")
+
+		result, err := Filter(descs, Options{Terms: []string{"foo"}, Rules: []Rule{constantRule(Keep)}})
+		if assert.NoError(t, err) {
+			assert.NotNil(t, result.Files[0].FindMessage("test.Message").FindFieldByName("annotated"))
+		}
+	})
+
+	t.Run("Should return the error of a rule with the element it failed on", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "rule.proto")
+		failing := RuleFunc(func(element Element) (Decision, error) {
+			if element.Kind == KindEnum {
+				return Abstain, errors.New("failed")
+			}
+			return Abstain, nil
+		})
+
+		_, err := Filter(descs, Options{Rules: []Rule{failing}})
+		assert.EqualError(t, err, "rule.proto: test.Enum: failed")
+	})
+
+	t.Run("Should be safe to share an annotation rule between concurrent Filter calls", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "rule.proto")
+		rule, err := NewAnnotationRule([]string{"foo", "partner.acme"})
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		results := make([]*Result, 8)
+		errs := make([]error, len(results))
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], errs[i] = Filter(descs, Options{Rules: []Rule{rule}})
+			}(i)
+		}
+		wg.Wait()
+
+		for i, result := range results {
+			if assert.NoError(t, errs[i]) {
+				message := result.Files[0].FindMessage("test.Message")
+				assert.Nil(t, message.FindFieldByName("annotated"))
+				assert.NotNil(t, message.FindFieldByName("beta"))
+				assert.NotNil(t, message.FindFieldByName("partner"))
+			}
+		}
+	})
+}
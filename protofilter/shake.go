@@ -0,0 +1,145 @@
+package protofilter
+
+import (
+	"sort"
+
+	"github.com/jhump/protoreflect/desc/builder"
+)
+
+// shakeTypes removes all messages and enums from the filtered files which can
+// not be reached from one of the roots. The roots are the request and response
+// types of every kept rpc method, the types used by kept extensions and every
+// type whose fully qualified name matches one of the patterns in roots.
+//
+// A type is reachable if a root refers to it, or if it is used by a field of a
+// reachable message. Reaching a nested type also keeps its enclosing types,
+// since those are part of its name.
+//
+// shakeTypes returns the sorted fully qualified names of the removed types.
+func shakeTypes(filtered map[string]*builder.FileBuilder, roots []termPattern) []string {
+	all := make(map[string]builder.Builder)
+	for _, fileBuilder := range filtered {
+		collectBuilders(fileBuilder, all)
+	}
+
+	reachable := reachableTypes(all, shakeRoots(all, roots))
+
+	var removed []string
+	var unreachable []builder.Builder
+	for name, b := range all {
+		switch b := b.(type) {
+		case *builder.MessageBuilder:
+			// Map entries are removed together with the message holding them
+			if _, ok := reachable[name]; !ok && !b.Options.GetMapEntry() {
+				removed = append(removed, name)
+				unreachable = append(unreachable, b)
+			}
+		case *builder.EnumBuilder:
+			if _, ok := reachable[name]; !ok {
+				removed = append(removed, name)
+				unreachable = append(unreachable, b)
+			}
+		}
+	}
+	for _, b := range unreachable {
+		builder.Unlink(b)
+	}
+
+	sort.Strings(removed)
+	return removed
+}
+
+// shakeRoots returns the fully qualified names of the types shakeTypes starts
+// from: the types of the methods and extensions, and the types matching one of
+// the roots
+func shakeRoots(all map[string]builder.Builder, roots []termPattern) []string {
+	result := make([]string, 0, len(all))
+	for name, b := range all {
+		switch b := b.(type) {
+		case *builder.MethodBuilder:
+			result = append(result, b.ReqType.GetTypeName(), b.RespType.GetTypeName())
+		case *builder.FieldBuilder:
+			if b.IsExtension() {
+				result = append(result, b.GetType().GetTypeName(), b.GetExtendeeTypeName())
+			}
+		case *builder.MessageBuilder, *builder.EnumBuilder:
+			for _, root := range roots {
+				if root.matchString(name) {
+					result = append(result, name)
+					break
+				}
+			}
+		}
+	}
+	return result
+}
+
+// reachableTypes returns the names of the types in all that can be reached
+// from the types in queue
+func reachableTypes(all map[string]builder.Builder, queue []string) map[string]struct{} {
+	reachable := make(map[string]struct{}, len(all))
+	for len(queue) != 0 {
+		name := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if _, seen := reachable[name]; seen {
+			continue
+		}
+		b, ok := all[name]
+		if !ok {
+			// Scalar types and types defined outside of the filtered files
+			continue
+		}
+		reachable[name] = struct{}{}
+
+		if parent := enclosingType(b); parent != nil {
+			queue = append(queue, builder.GetFullyQualifiedName(parent))
+		}
+		if messageBuilder, ok := b.(*builder.MessageBuilder); ok {
+			queue = append(queue, messageFieldTypes(messageBuilder)...)
+		}
+	}
+	return reachable
+}
+
+// enclosingType returns the message that contains the nested type b, or nil
+// if b is not nested in a message. Map entries and groups, which are children
+// of a field, are attributed to the message holding that field.
+func enclosingType(b builder.Builder) *builder.MessageBuilder {
+	parent := b.GetParent()
+	if _, ok := parent.(*builder.FieldBuilder); ok {
+		parent = parent.GetParent()
+	}
+	if _, ok := parent.(*builder.OneOfBuilder); ok {
+		parent = parent.GetParent()
+	}
+	messageBuilder, _ := parent.(*builder.MessageBuilder)
+	return messageBuilder
+}
+
+// messageFieldTypes returns the names of the types used by the fields of the
+// message (including oneof choices), but not those of its nested types
+func messageFieldTypes(messageBuilder *builder.MessageBuilder) []string {
+	var result []string
+	for _, child := range messageBuilder.GetChildren() {
+		switch c := child.(type) {
+		case *builder.FieldBuilder:
+			if !c.IsExtension() {
+				result = append(result, c.GetType().GetTypeName())
+			}
+		case *builder.OneOfBuilder:
+			for _, choice := range c.GetChildren() {
+				result = append(result, choice.(*builder.FieldBuilder).GetType().GetTypeName())
+			}
+		}
+	}
+	return result
+}
+
+// matchString returns true if s is equal to the term, or matches it if the
+// term is a pattern
+func (p termPattern) matchString(s string) bool {
+	if p.re == nil {
+		return p.raw == s
+	}
+	return p.re.MatchString(s)
+}
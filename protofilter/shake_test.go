@@ -0,0 +1,97 @@
+package protofilter
+
+import (
+	"testing"
+
+	"github.com/Workiva/go-datastructures/set"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const shakeTestProto = `
+syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+
+message Request {
+    Nested.Inner inner = 1;
+    map<string, Value> values = 2;
+}
+
+message Response {
+    oneof result {
+        Status status = 1;
+    }
+}
+
+message Nested {
+    message Inner {}
+    message Unused {}
+}
+
+message Value {}
+
+enum Status {
+    STATUS_UNKNOWN = 0;
+}
+
+message InternalRequest {
+    InternalDetail detail = 1;
+}
+
+message InternalDetail {}
+
+message Orphan {
+    map<string, string> tags = 1;
+}
+
+message KeptRoot {}
+
+service Service {
+    rpc Public(Request) returns (Response);
+    rpc Internal(InternalRequest) returns (Response) {
+        option (filter.method).exclude = "public";
+    }
+}
+`
+
+func TestShakeTypes(t *testing.T) {
+	files := map[string]string{"shake.proto": shakeTestProto}
+
+	t.Run("Should remove all types that are not reachable from a kept service", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "shake.proto")
+		filtered := filterTestFiles(t, descs, set.New("public"))
+
+		removed := shakeTypes(filtered, nil)
+		assert.Equal(t, []string{
+			"test.InternalDetail",
+			"test.InternalRequest",
+			"test.KeptRoot",
+			"test.Nested.Unused",
+			"test.Orphan",
+		}, removed)
+
+		fileBuilder := filtered["shake.proto"]
+		assert.NotNil(t, fileBuilder.GetMessage("Nested"), "Expected the enclosing type of a reachable nested type to be kept")
+		assert.NotNil(t, fileBuilder.GetMessage("Request").GetNestedMessage("ValuesEntry"), "Expected the map entry of a reachable message to be kept")
+		assert.Nil(t, fileBuilder.GetMessage("Orphan"))
+		_, err := fileBuilder.Build()
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should keep types that match one of the roots", func(t *testing.T) {
+		descs := parseTestFiles(t, files, "shake.proto")
+		filtered := filterTestFiles(t, descs, set.New("public"))
+
+		roots := make([]termPattern, 0, 2)
+		for _, root := range []string{"test.KeptRoot", "test.Internal*"} {
+			pattern, err := compileTerm(root)
+			require.NoError(t, err)
+			roots = append(roots, pattern)
+		}
+
+		removed := shakeTypes(filtered, roots)
+		assert.Equal(t, []string{"test.Nested.Unused", "test.Orphan"}, removed)
+	})
+}
@@ -0,0 +1,174 @@
+package protofilter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Workiva/go-datastructures/set"
+)
+
+// termPattern is the compiled form of a single term. A term is either a
+// literal, a glob pattern (containing `*` or `?`) or a regular expression
+// (enclosed in slashes: `/^partner\.(acme|globex)$/`).
+type termPattern struct {
+	raw string
+	re  *regexp.Regexp // nil for a literal term
+}
+
+// compileTerm compiles the textual representation of a term into a termPattern
+func compileTerm(raw string) (termPattern, error) {
+	if len(raw) > 2 && strings.HasPrefix(raw, "/") && strings.HasSuffix(raw, "/") {
+		re, err := regexp.Compile(raw[1 : len(raw)-1])
+		if err != nil {
+			return termPattern{}, fmt.Errorf("Invalid regular expression in term %q: %v", raw, err)
+		}
+		return termPattern{raw: raw, re: re}, nil
+	}
+	if strings.ContainsAny(raw, "*?") {
+		return termPattern{raw: raw, re: compileGlob(raw)}, nil
+	}
+	return termPattern{raw: raw}, nil
+}
+
+// ValidateTerm returns an error if the term is not a valid literal, glob or
+// regular expression
+func ValidateTerm(term string) error {
+	_, err := compileTerm(term)
+	return err
+}
+
+// compileGlob converts a glob pattern into an anchored regular expression:
+// `*` matches any sequence of characters and `?` matches exactly one character
+func compileGlob(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// termSet is the compiled form of the terms that are being filtered for. It
+// matches the terms used in annotations against them, supporting patterns on
+// both sides: an annotation pattern matches any literal term given to the
+// program and a pattern given to the program matches any literal annotation
+// term.
+//
+// Annotation terms and expressions are compiled on first use and cached, so
+// every one of them is only compiled once per run. The caches are guarded by
+// mu, so a termSet can be shared by concurrent Filter calls.
+type termSet struct {
+	literals    []string
+	literalSet  *set.Set
+	patterns    []termPattern
+	mu          sync.Mutex
+	compiled    map[string]termPattern
+	expressions map[string]expression
+}
+
+// newTermSet compiles the given terms into a termSet. It returns an error if
+// any of the terms is an invalid pattern.
+func newTermSet(terms *set.Set) (*termSet, error) {
+	result := &termSet{
+		literalSet:  set.New(),
+		compiled:    map[string]termPattern{},
+		expressions: map[string]expression{},
+	}
+	if terms == nil {
+		return result, nil
+	}
+	for _, item := range terms.Flatten() {
+		pattern, err := compileTerm(item.(string))
+		if err != nil {
+			return nil, err
+		}
+		if pattern.re == nil {
+			result.literals = append(result.literals, pattern.raw)
+			result.literalSet.Add(pattern.raw)
+		} else {
+			result.patterns = append(result.patterns, pattern)
+		}
+	}
+	return result, nil
+}
+
+// Len returns the number of terms in the set
+func (t *termSet) Len() int {
+	return len(t.literals) + len(t.patterns)
+}
+
+// matchesTerm returns true if the annotation term matches any of the terms in the
+// set. It returns an error if the annotation term is an invalid pattern.
+func (t *termSet) matchesTerm(term string) (bool, error) {
+	pattern, err := t.compile(term)
+	if err != nil {
+		return false, err
+	}
+	return t.matches(pattern), nil
+}
+
+func (t *termSet) matches(pattern termPattern) bool {
+	if t.literalSet.Exists(pattern.raw) {
+		return true
+	}
+	if pattern.re != nil {
+		for _, literal := range t.literals {
+			if pattern.re.MatchString(literal) {
+				return true
+			}
+		}
+		// Two patterns can only be matched if they are identical
+		for _, p := range t.patterns {
+			if p.raw == pattern.raw {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range t.patterns {
+		if p.re.MatchString(pattern.raw) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *termSet) compile(term string) (termPattern, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if pattern, ok := t.compiled[term]; ok {
+		return pattern, nil
+	}
+	pattern, err := compileTerm(term)
+	if err != nil {
+		return termPattern{}, err
+	}
+	t.compiled[term] = pattern
+	return pattern, nil
+}
+
+// parseExpression returns the parsed form of the given expression, reusing an
+// earlier result if the same expression was already seen
+func (t *termSet) parseExpression(input string) (expression, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if expr, ok := t.expressions[input]; ok {
+		return expr, nil
+	}
+	expr, err := parseExpression(input)
+	if err != nil {
+		return nil, err
+	}
+	t.expressions[input] = expr
+	return expr, nil
+}
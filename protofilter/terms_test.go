@@ -0,0 +1,112 @@
+package protofilter
+
+import (
+	"testing"
+
+	"github.com/Workiva/go-datastructures/set"
+	"github.com/stretchr/testify/assert"
+)
+
+// mustNewTermSet is a test helper that compiles terms into a termSet and
+// panics if that fails
+func mustNewTermSet(terms *set.Set) *termSet {
+	result, err := newTermSet(terms)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func TestTermSetMatchesTerm(t *testing.T) {
+	cases := []struct {
+		name   string
+		terms  *set.Set
+		input  string
+		output bool
+	}{
+		{
+			name:   "Should match an identical literal term",
+			terms:  set.New("partner.acme"),
+			input:  "partner.acme",
+			output: true,
+		},
+		{
+			name:   "Should not match a different literal term",
+			terms:  set.New("partner.acme"),
+			input:  "partner.globex",
+			output: false,
+		},
+		{
+			name:   "Should match a literal term against a glob in the terms",
+			terms:  set.New("partner.*"),
+			input:  "partner.globex",
+			output: true,
+		},
+		{
+			name:   "Should match a glob in the annotation against a literal term",
+			terms:  set.New("internal.ops"),
+			input:  "internal.*",
+			output: true,
+		},
+		{
+			name:   "Should not match a glob against a literal with a different prefix",
+			terms:  set.New("internal.ops"),
+			input:  "partner.*",
+			output: false,
+		},
+		{
+			name:   "Should match `?` against exactly one character",
+			terms:  set.New("region-e?"),
+			input:  "region-eu",
+			output: true,
+		},
+		{
+			name:   "Should match identical globs",
+			terms:  set.New("partner.*"),
+			input:  "partner.*",
+			output: true,
+		},
+		{
+			name:   "Should match a regular expression in the terms",
+			terms:  set.New(`/^partner\.(acme|globex)$/`),
+			input:  "partner.acme",
+			output: true,
+		},
+		{
+			name:   "Should match a regular expression in the annotation",
+			terms:  set.New("partner.globex"),
+			input:  `/^partner\.(acme|globex)$/`,
+			output: true,
+		},
+		{
+			name:   "Should treat regular expression meta characters in a glob literally",
+			terms:  set.New("partnerXacme"),
+			input:  "partner.*e",
+			output: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result, err := mustNewTermSet(tc.terms).matchesTerm(tc.input); assert.NoError(t, err) {
+				assert.Equal(t, tc.output, result)
+			}
+		})
+	}
+
+	t.Run("Should return an error for an invalid regular expression in the annotation", func(t *testing.T) {
+		_, err := mustNewTermSet(set.New("foo")).matchesTerm("/(foo/")
+		assert.Error(t, err)
+	})
+}
+
+func TestNewTermSet(t *testing.T) {
+	t.Run("Should return an error for an invalid regular expression", func(t *testing.T) {
+		_, err := newTermSet(set.New("foo", "/(foo/"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Should count literals and patterns", func(t *testing.T) {
+		assert.Equal(t, 3, mustNewTermSet(set.New("foo", "bar.*", "/baz/")).Len())
+	})
+}
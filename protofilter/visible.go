@@ -0,0 +1,92 @@
+package protofilter
+
+import "github.com/jhump/protoreflect/desc"
+
+// Visible reports whether Filter keeps the element described by d for the
+// options. It applies the rules and annotations of the element and its
+// ancestors, without filtering the whole file, so it is cheap enough to call
+// for every request a server handles.
+//
+// Only the rules and the Policy are taken into account: an element that
+// Filter would remove because of a dangling reference or Shake is visible.
+func Visible(d desc.Descriptor, options Options) (bool, error) {
+	checker, err := NewVisibilityChecker(options)
+	if err != nil {
+		return false, err
+	}
+	return checker.Visible(d)
+}
+
+// VisibilityChecker reports whether elements are visible for a fixed set of
+// options, see Visible. The rule is built once, so a checker can be reused for
+// every element and request with the same options, also concurrently.
+type VisibilityChecker struct {
+	rule   Rule
+	policy Policy
+}
+
+// NewVisibilityChecker returns a checker for the options. It returns an error
+// if the rule can not be built from the options, for example for an invalid
+// term.
+func NewVisibilityChecker(options Options) (*VisibilityChecker, error) {
+	rule, err := newRule(options)
+	if err != nil {
+		return nil, err
+	}
+	return &VisibilityChecker{rule: rule, policy: options.Policy}, nil
+}
+
+// Visible reports whether Filter keeps the element described by d
+func (c *VisibilityChecker) Visible(d desc.Descriptor) (bool, error) {
+	var path []desc.Descriptor
+	for ; d != nil; d = parentOf(d) {
+		path = append([]desc.Descriptor{d}, path...)
+	}
+
+	scope := filterScope{rule: c.rule, policy: c.policy}
+	var result Decision
+	var err error
+	for _, element := range path {
+		if result, scope, err = scope.judge(element); err != nil {
+			return false, err
+		}
+	}
+	d = path[len(path)-1]
+	if visible, err := scope.visible(d, result); err != nil || visible {
+		return visible, err
+	}
+	// Filter keeps the zero value of a proto3 enum whenever it keeps the enum
+	if value, ok := d.(*desc.EnumValueDescriptor); ok && value.GetNumber() == 0 && value.GetFile().IsProto3() {
+		return c.Visible(value.GetParent())
+	}
+	return false, nil
+}
+
+// parentOf returns the element that encloses d while filtering, which is the
+// oneof for the fields of a oneof
+func parentOf(d desc.Descriptor) desc.Descriptor {
+	if field, ok := d.(*desc.FieldDescriptor); ok && field.GetOneOf() != nil {
+		return field.GetOneOf()
+	}
+	return d.GetParent()
+}
+
+// visible reports whether an element with the effective decision result is
+// kept. A dropped element, and under the deny policy an element without a
+// decision, is only kept if one of its descendants is. Files are only removed
+// by a Drop.
+func (s filterScope) visible(d desc.Descriptor, result Decision) (bool, error) {
+	if _, isFile := d.(*desc.FileDescriptor); (isFile && result != Drop) || !s.isRemoved(result, false) {
+		return true, nil
+	}
+	for _, child := range childDescriptors(d) {
+		childResult, childScope, err := s.judge(child)
+		if err != nil {
+			return false, err
+		}
+		if visible, err := childScope.visible(child, childResult); err != nil || visible {
+			return visible, err
+		}
+	}
+	return false, nil
+}
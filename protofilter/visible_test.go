@@ -0,0 +1,118 @@
+package protofilter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const visibleTestProto = `
+syntax = "proto3";
+package test;
+
+import "filter/filter.proto";
+import "google/protobuf/empty.proto";
+
+message Message {
+    option (filter.message).include = "NA";
+    string name = 1;
+    string jp = 2 [(filter.field).include = "JP"];
+    string not_eu = 3 [(filter.field).exclude = "EU"];
+    oneof choice {
+        string first = 4 [(filter.field).include = "EU"];
+        string second = 5;
+    }
+    message Nested {
+        string id = 1 [(filter.field).include = "EU"];
+    }
+}
+
+enum Enum {
+    DEFAULT = 0;
+    JP_ONLY = 1 [(filter.enum_value).include = "JP"];
+}
+
+service Service {
+    option (filter.service).exclude = "JP";
+    rpc Get(google.protobuf.Empty) returns (google.protobuf.Empty);
+    rpc Partner(google.protobuf.Empty) returns (google.protobuf.Empty) {
+        option (filter.method).include = "partner.*";
+    }
+}
+`
+
+func TestVisible(t *testing.T) {
+	descs := parseTestFiles(t, map[string]string{"visible.proto": visibleTestProto}, "visible.proto")
+	elements := make(map[string]struct{})
+	collectDescriptors(descs[0], elements)
+
+	for _, policy := range []Policy{PolicyAllow, PolicyDeny} {
+		for _, terms := range [][]string{nil, {"NA"}, {"JP"}, {"EU"}, {"NA", "EU"}, {"partner.acme"}, {"JP", "partner.acme"}} {
+			t.Run(fmt.Sprintf("Should agree with Filter for %v with policy %s", terms, policy), func(t *testing.T) {
+				options := Options{Terms: terms, Policy: policy}
+				result, err := Filter(descs, options)
+				require.NoError(t, err)
+				removed := make(map[string]struct{})
+				for _, removal := range result.Removed {
+					removed[removal.Name] = struct{}{}
+				}
+
+				for name := range elements {
+					d := descs[0].FindSymbol(name)
+					if d == nil {
+						d = descs[0]
+					}
+					visible, err := Visible(d, options)
+					if assert.NoError(t, err, name) {
+						_, isRemoved := removed[name]
+						assert.Equal(t, !isRemoved, visible, name)
+					}
+				}
+			})
+		}
+	}
+
+	t.Run("Should apply the rules", func(t *testing.T) {
+		method := descs[0].FindService("test.Service").FindMethodByName("Get")
+		dropMethods := RuleFunc(func(element Element) (Decision, error) {
+			if _, ok := element.Descriptor.(*desc.MethodDescriptor); ok {
+				return Drop, nil
+			}
+			return Abstain, nil
+		})
+
+		visible, err := Visible(method, Options{Rules: []Rule{dropMethods}})
+		if assert.NoError(t, err) {
+			assert.False(t, visible)
+		}
+	})
+
+	t.Run("Should return an error for an invalid term", func(t *testing.T) {
+		_, err := Visible(descs[0], Options{Terms: []string{"/[/"}})
+		assert.Error(t, err)
+		_, err = NewVisibilityChecker(Options{Terms: []string{"/[/"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("Should agree with Visible when a checker is reused", func(t *testing.T) {
+		options := Options{Terms: []string{"JP", "partner.acme"}, Policy: PolicyDeny}
+		checker, err := NewVisibilityChecker(options)
+		require.NoError(t, err)
+
+		for name := range elements {
+			d := descs[0].FindSymbol(name)
+			if d == nil {
+				d = descs[0]
+			}
+			expected, err := Visible(d, options)
+			require.NoError(t, err)
+			visible, err := checker.Visible(d)
+			if assert.NoError(t, err, name) {
+				assert.Equal(t, expected, visible, name)
+			}
+		}
+	})
+}
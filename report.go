@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/wdullaer/proto-filter/protofilter"
+)
+
+// Report is the machine readable summary of a run written by --report
+type Report struct {
+	Variants []VariantReport `json:"variants"`
+}
+
+// VariantReport lists everything that was removed from a single variant
+type VariantReport struct {
+	Name    string          `json:"name,omitempty"`
+	Removed []ReportElement `json:"removed"`
+	Totals  ReportTotals    `json:"totals"`
+}
+
+// ReportElement is a single removed element
+type ReportElement struct {
+	Kind        string   `json:"kind"`
+	Name        string   `json:"name"`
+	File        string   `json:"file"`
+	Line        int      `json:"line,omitempty"`
+	Column      int      `json:"column,omitempty"`
+	Rule        string   `json:"rule,omitempty"`
+	Terms       []string `json:"terms,omitempty"`
+	RemovedWith string   `json:"removed_with,omitempty"`
+	DecidedBy   string   `json:"decided_by,omitempty"`
+}
+
+// ReportTotals counts the removed elements
+type ReportTotals struct {
+	Removed int            `json:"removed"`
+	Files   map[string]int `json:"files"`
+	Kinds   map[string]int `json:"kinds"`
+}
+
+// newVariantReport creates the report of a variant from the removals in its
+// result
+func newVariantReport(name string, removals []protofilter.Removal) VariantReport {
+	report := VariantReport{
+		Name:    name,
+		Removed: make([]ReportElement, len(removals)),
+		Totals: ReportTotals{
+			Removed: len(removals),
+			Files:   make(map[string]int),
+			Kinds:   make(map[string]int),
+		},
+	}
+	for i, removal := range removals {
+		report.Removed[i] = ReportElement{
+			Kind:        removal.Kind.String(),
+			Name:        removal.Name,
+			File:        removal.File,
+			Line:        removal.Line,
+			Column:      removal.Column,
+			Rule:        removal.Reason.Rule,
+			Terms:       removal.Reason.Terms,
+			RemovedWith: removal.RemovedWith,
+			DecidedBy:   removal.DecidedBy,
+		}
+		report.Totals.Files[removal.File]++
+		report.Totals.Kinds[removal.Kind.String()]++
+	}
+	return report
+}
+
+// writeReport writes the report to path as indented JSON. The keys of the
+// totals are sorted, so reports of different runs can be diffed.
+func writeReport(report Report, path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
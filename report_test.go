@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wdullaer/proto-filter/protofilter"
+)
+
+func TestNewVariantReport(t *testing.T) {
+	t.Run("Should list the removals with per file and per kind totals", func(t *testing.T) {
+		removals := []protofilter.Removal{
+			{Kind: protofilter.KindMessage, Name: "test.Foo", File: "a.proto", Line: 3, Column: 1, Reason: protofilter.Reason{Rule: "annotation exclude", Terms: []string{"NA"}}},
+			{Kind: protofilter.KindField, Name: "test.Foo.bar", File: "a.proto", Line: 4, Column: 5, Reason: protofilter.Reason{Rule: "annotation exclude", Terms: []string{"NA"}}, RemovedWith: "test.Foo"},
+			{Kind: protofilter.KindField, Name: "test.Baz.qux", File: "b.proto", Reason: protofilter.Reason{Rule: "shake"}},
+		}
+
+		report := newVariantReport("na", removals)
+
+		assert.Equal(t, "na", report.Name)
+		assert.Equal(t, ReportElement{
+			Kind:        "field",
+			Name:        "test.Foo.bar",
+			File:        "a.proto",
+			Line:        4,
+			Column:      5,
+			Rule:        "annotation exclude",
+			Terms:       []string{"NA"},
+			RemovedWith: "test.Foo",
+		}, report.Removed[1])
+		assert.Equal(t, ReportTotals{
+			Removed: 3,
+			Files:   map[string]int{"a.proto": 2, "b.proto": 1},
+			Kinds:   map[string]int{"message": 1, "field": 2},
+		}, report.Totals)
+	})
+}
+
+func TestWriteReport(t *testing.T) {
+	t.Run("Should write the report as JSON", func(t *testing.T) {
+		dir, cleanup := tempDir(t)
+		defer cleanup()
+		path := filepath.Join(dir, "report.json")
+
+		report := Report{Variants: []VariantReport{newVariantReport("", []protofilter.Removal{
+			{Kind: protofilter.KindEnumValue, Name: "test.Enum.VALUE", File: "a.proto", Line: 7, Column: 3, Reason: protofilter.Reason{Rule: "annotation include without a matching term"}},
+		})}}
+		require.NoError(t, writeReport(report, path))
+
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+  "variants": [{
+    "removed": [{
+      "kind": "enum value",
+      "name": "test.Enum.VALUE",
+      "file": "a.proto",
+      "line": 7,
+      "column": 3,
+      "rule": "annotation include without a matching term"
+    }],
+    "totals": {"removed": 1, "files": {"a.proto": 1}, "kinds": {"enum value": 1}}
+  }]
+}`, string(data))
+	})
+}